@@ -4,26 +4,26 @@ package accounts
 type Account struct {
 	ID           int64           `json:"id"`
 	Email        string          `json:"email"`
-	FName        string          `json:"fname"`
-	SName        string          `json:"sname"`
-	Phone        string          `json:"phone"`
+	FName        string          `json:"fname,omitempty"`
+	SName        string          `json:"sname,omitempty"`
+	Phone        string          `json:"phone,omitempty"`
 	Sex          string          `json:"sex"`
 	Birth        int64           `json:"birth"`
-	Country      string          `json:"country"`
-	City         string          `json:"city"`
+	Country      string          `json:"country,omitempty"`
+	City         string          `json:"city,omitempty"`
 	Joined       int64           `json:"joined"`
 	Status       string          `json:"status"`
 	InterestsMap map[string]bool `json:"-"`
-	Interests    []string        `json:"interests"`
-	Premium      *Premium        `json:"premium"`
-	Likes        []*Like         `json:"likes"`
+	Interests    []string        `json:"interests,omitempty"`
+	Premium      *Premium        `json:"premium,omitempty"`
+	Likes        []*Like         `json:"likes,omitempty"`
 	LikesMap     map[string]bool `json:"-"`
 }
 
 // Like is a account's like.
 type Like struct {
 	ID        int64 `json:"id"`
-	Timestamp int64 `json:"dt"`
+	Timestamp int64 `json:"ts"`
 }
 
 // Premium contains information about account premium, period.
@@ -31,3 +31,26 @@ type Premium struct {
 	Start  int64 `json:"start"`
 	Finish int64 `json:"finish"`
 }
+
+// Clone returns a deep copy of the account.
+func (a *Account) Clone() *Account {
+	c := *a
+	if a.Interests != nil {
+		c.Interests = make([]string, len(a.Interests))
+		copy(c.Interests, a.Interests)
+	}
+	if a.Premium != nil {
+		c.Premium = &Premium{}
+		*c.Premium = *a.Premium
+	}
+	if a.Likes != nil {
+		c.Likes = make([]*Like, 0, len(a.Likes))
+		for _, l := range a.Likes {
+			like := *l
+			c.Likes = append(c.Likes, &like)
+		}
+	}
+	c.InterestsMap = nil
+	c.LikesMap = nil
+	return &c
+}
@@ -0,0 +1,87 @@
+package accounts_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/ngalayko/highloadcup/app/accounts"
+)
+
+func FuzzAccountJSON(f *testing.F) {
+	f.Add([]byte(`{"id":1,"email":"a@b.ru","sex":"m","status":"свободны"}`))
+	f.Add([]byte(`{"id":2,"email":"c@d.com","fname":"Анна","sname":"Данатокая","phone":"8(912)1234567","sex":"f","birth":-100,"country":"Росезия","city":"Лесогама","joined":1400000000,"status":"заняты","interests":["Пиво","PS3"],"premium":{"start":1,"finish":2},"likes":[{"id":1,"ts":3}]}`))
+	f.Add([]byte(`{"id":3,"email":"e@f","sex":"m","status":"всё сложно","interests":[],"likes":[]}`))
+	f.Add([]byte(`{"id":0,"email":"x","sex":"x","status":"x"}`))
+	f.Add([]byte(`{"id":4,"email":"g@h","sex":"f","status":"заняты","likes":[null],"premium":null}`))
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		a := &accounts.Account{}
+		if err := json.Unmarshal(data, a); err != nil {
+			return
+		}
+		if err := a.Validate(); err != nil {
+			return
+		}
+
+		encoded, err := json.Marshal(a)
+		if err != nil {
+			t.Fatalf("can't encode valid account %s: %s", data, err)
+		}
+
+		decoded := &accounts.Account{}
+		if err := json.Unmarshal(encoded, decoded); err != nil {
+			t.Fatalf("can't decode encoded account %s: %s", encoded, err)
+		}
+		if err := decoded.Validate(); err != nil {
+			t.Fatalf("encoded account %s is invalid: %s", encoded, err)
+		}
+
+		reencoded, err := json.Marshal(decoded)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(encoded, reencoded) {
+			t.Fatalf("account changed after round trip: %s != %s", encoded, reencoded)
+		}
+
+		cloned, err := json.Marshal(a.Clone())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(encoded, cloned) {
+			t.Fatalf("account changed after clone: %s != %s", encoded, cloned)
+		}
+	})
+}
+
+func FuzzNewLikesJSON(f *testing.F) {
+	f.Add([]byte(`{"likes":[{"likee":1,"liker":2,"ts":3}]}`))
+	f.Add([]byte(`{"likes":[]}`))
+	f.Add([]byte(`{"likes":[{"likee":"1"}]}`))
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		likes := &accounts.NewLikes{}
+		if err := json.Unmarshal(data, likes); err != nil {
+			return
+		}
+
+		encoded, err := json.Marshal(likes)
+		if err != nil {
+			t.Fatalf("can't encode likes %s: %s", data, err)
+		}
+
+		decoded := &accounts.NewLikes{}
+		if err := json.Unmarshal(encoded, decoded); err != nil {
+			t.Fatalf("can't decode encoded likes %s: %s", encoded, err)
+		}
+
+		reencoded, err := json.Marshal(decoded)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(encoded, reencoded) {
+			t.Fatalf("likes changed after round trip: %s != %s", encoded, reencoded)
+		}
+	})
+}
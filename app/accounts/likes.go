@@ -0,0 +1,13 @@
+package accounts
+
+// NewLike is a like added after the initial import.
+type NewLike struct {
+	Likee     int64 `json:"likee"`
+	Liker     int64 `json:"liker"`
+	Timestamp int64 `json:"ts"`
+}
+
+// NewLikes is a batch of new likes.
+type NewLikes struct {
+	Likes []*NewLike `json:"likes"`
+}
@@ -0,0 +1,43 @@
+package accounts
+
+import (
+	"errors"
+	"strings"
+)
+
+// Statuses are allowed account statuses.
+var Statuses = map[string]bool{
+	"свободны":   true,
+	"заняты":     true,
+	"всё сложно": true,
+}
+
+var (
+	errInvalidID     = errors.New("invalid id")
+	errInvalidEmail  = errors.New("invalid email")
+	errInvalidSex    = errors.New("invalid sex")
+	errInvalidStatus = errors.New("invalid status")
+	errInvalidLike   = errors.New("invalid like")
+)
+
+// Validate returns an error if account fields have invalid values.
+func (a *Account) Validate() error {
+	if a.ID <= 0 {
+		return errInvalidID
+	}
+	if !strings.Contains(a.Email, "@") {
+		return errInvalidEmail
+	}
+	if ParseSex([]byte(a.Sex)) == SexUndefined {
+		return errInvalidSex
+	}
+	if !Statuses[a.Status] {
+		return errInvalidStatus
+	}
+	for _, like := range a.Likes {
+		if like == nil {
+			return errInvalidLike
+		}
+	}
+	return nil
+}
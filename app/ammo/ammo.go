@@ -0,0 +1,112 @@
+// Package ammo parses Yandex.Tank ammo files shared by the tester
+// and application tests.
+package ammo
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Request is a single request from an ammo file.
+type Request struct {
+	Tag    string
+	Method string
+	URI    string
+	Header http.Header
+	Body   []byte
+}
+
+// Parse parses requests from a file in Yandex.Tank raw format.
+func Parse(dataPath string) ([]*Request, error) {
+	file, err := os.Open(dataPath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	return Read(file)
+}
+
+// Read reads requests in Yandex.Tank raw format: a line with request size
+// and optional tag, followed by a raw http request.
+func Read(reader io.Reader) ([]*Request, error) {
+	requests := []*Request{}
+
+	r := bufio.NewReader(reader)
+	for {
+		line, err := r.ReadString('\n')
+		if err == io.EOF && strings.TrimSpace(line) == "" {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+
+		request, err := parseRequest(r, line)
+		if err != nil {
+			return nil, fmt.Errorf("can't parse request %d: %s", len(requests)+1, err)
+		}
+
+		requests = append(requests, request)
+	}
+
+	return requests, nil
+}
+
+func parseRequest(r io.Reader, sizeLine string) (*Request, error) {
+	parts := strings.SplitN(sizeLine, " ", 2)
+	size, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return nil, err
+	}
+	if size <= 0 {
+		return nil, fmt.Errorf("invalid request size %d", size)
+	}
+
+	raw := make([]byte, size)
+	if _, err := io.ReadFull(r, raw); err != nil {
+		return nil, err
+	}
+
+	req, err := http.ReadRequest(bufio.NewReader(bytes.NewReader(raw)))
+	if err != nil {
+		return nil, err
+	}
+	defer req.Body.Close()
+
+	body, err := ioutil.ReadAll(req.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	request := &Request{
+		Method: req.Method,
+		URI:    req.RequestURI,
+		Header: req.Header,
+		Body:   body,
+	}
+	if len(parts) == 2 {
+		request.Tag = parts[1]
+	}
+	return request, nil
+}
+
+// Path returns request uri path without a query.
+func (r *Request) Path() string {
+	if i := strings.IndexByte(r.URI, '?'); i >= 0 {
+		return r.URI[:i]
+	}
+	return r.URI
+}
@@ -0,0 +1,126 @@
+package ammo
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// shot formats a raw request with a header line.
+func shot(header string, raw string) string {
+	return fmt.Sprintf(header+"\n%s", len(raw), raw)
+}
+
+const (
+	getRequest  = "GET /accounts/filter/?sex_eq=m&limit=10 HTTP/1.1\r\nHost: localhost\r\n\r\n"
+	postRequest = "POST /accounts/new/?query_id=1 HTTP/1.1\r\nHost: localhost\r\nContent-Type: application/json\r\nContent-Length: 8\r\n\r\n{\"id\":1}"
+)
+
+func TestRead(t *testing.T) {
+	for _, c := range []struct {
+		name     string
+		data     string
+		expected []*Request
+		err      bool
+	}{
+		{
+			name:     "empty",
+			data:     "",
+			expected: []*Request{},
+		},
+		{
+			name: "header line with a tag",
+			data: shot("%d GET:/accounts/filter/", getRequest),
+			expected: []*Request{
+				{Tag: "GET:/accounts/filter/", Method: "GET", URI: "/accounts/filter/?sex_eq=m&limit=10", Body: []byte{}},
+			},
+		},
+		{
+			name: "header line without a tag",
+			data: shot("%d", getRequest),
+			expected: []*Request{
+				{Method: "GET", URI: "/accounts/filter/?sex_eq=m&limit=10", Body: []byte{}},
+			},
+		},
+		{
+			name: "body of content length",
+			data: shot("%d POST:/accounts/new/", postRequest),
+			expected: []*Request{
+				{Tag: "POST:/accounts/new/", Method: "POST", URI: "/accounts/new/?query_id=1", Body: []byte(`{"id":1}`)},
+			},
+		},
+		{
+			name: "multiple requests separated by blank lines",
+			data: shot("%d POST:/accounts/new/", postRequest) + "\n\n" + shot("%d GET:/accounts/filter/", getRequest) + "\n",
+			expected: []*Request{
+				{Tag: "POST:/accounts/new/", Method: "POST", URI: "/accounts/new/?query_id=1", Body: []byte(`{"id":1}`)},
+				{Tag: "GET:/accounts/filter/", Method: "GET", URI: "/accounts/filter/?sex_eq=m&limit=10", Body: []byte{}},
+			},
+		},
+		{
+			name: "invalid size",
+			data: "size GET:/accounts/filter/\n" + getRequest,
+			err:  true,
+		},
+		{
+			name: "negative size",
+			data: "-1 GET:/accounts/filter/\n" + getRequest,
+			err:  true,
+		},
+		{
+			name: "body shorter than content length",
+			data: shot("%d POST:/accounts/new/", strings.Replace(postRequest, "Content-Length: 8", "Content-Length: 20", 1)),
+			err:  true,
+		},
+		{
+			name: "truncated final request",
+			data: shot("%d GET:/accounts/filter/", getRequest) + "\n" + shot("%d POST:/accounts/new/", postRequest)[:40],
+			err:  true,
+		},
+		{
+			name: "header line without a request",
+			data: shot("%d GET:/accounts/filter/", getRequest) + "\n100 GET:/accounts/filter/",
+			err:  true,
+		},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			rr, err := Read(strings.NewReader(c.data))
+			if c.err {
+				if err == nil {
+					t.Fatalf("expected an error, got %d requests", len(rr))
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if len(rr) != len(c.expected) {
+				t.Fatalf("expected %d requests, got %d", len(c.expected), len(rr))
+			}
+			for i, r := range rr {
+				if len(r.Body) != 0 && r.Header.Get("Content-Type") != "application/json" {
+					t.Fatalf("request %d: expected a json content type, got %v", i, r.Header)
+				}
+				got := *r
+				got.Header = nil
+				if !reflect.DeepEqual(&got, c.expected[i]) {
+					t.Fatalf("request %d: expected %+v, got %+v", i, c.expected[i], &got)
+				}
+			}
+		})
+	}
+}
+
+func TestRequestPath(t *testing.T) {
+	for uri, expected := range map[string]string{
+		"/accounts/filter/?sex_eq=m": "/accounts/filter/",
+		"/accounts/1/":               "/accounts/1/",
+		"/accounts/1/?":              "/accounts/1/",
+	} {
+		if path := (&Request{URI: uri}).Path(); path != expected {
+			t.Fatalf("%s: expected path %s, got %s", uri, expected, path)
+		}
+	}
+}
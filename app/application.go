@@ -1,31 +1,129 @@
 package app
 
 import (
+	"bytes"
+	"errors"
+	"io"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
 	"github.com/ngalayko/highloadcup/app/datastore"
+	exportzip "github.com/ngalayko/highloadcup/app/exporter/zip"
 	"github.com/ngalayko/highloadcup/app/importer/zip"
 	"github.com/ngalayko/highloadcup/app/logger"
 	"github.com/ngalayko/highloadcup/app/web"
 )
 
+// ErrNotLoaded is returned by Export before data is loaded.
+var ErrNotLoaded = errors.New("data is not loaded")
+
 // Application is a main object.
 type Application struct {
+	log      *logger.Logger
+	dataPath string
+	// dataOptions is options.txt of the dataset.
+	dataOptions []byte
+	opts        *options
+	progress    *datastore.Progress
+
+	mu        sync.Mutex
 	datastore *datastore.Datastore
 	web       *web.Web
 }
 
-// New is the application constructor.
-func New(dataPath string) (*Application, error) {
-	logger := logger.New()
+// options are options of application parts.
+type options struct {
+	datastore []datastore.Option
+	web       []web.Option
+}
+
+// Option is an application option.
+type Option func(*options)
+
+// WithDatastore passes options to the datastore.
+func WithDatastore(opts ...datastore.Option) Option {
+	return func(o *options) {
+		o.datastore = append(o.datastore, opts...)
+	}
+}
+
+// WithWeb passes options to the web server.
+func WithWeb(opts ...web.Option) Option {
+	return func(o *options) {
+		o.web = append(o.web, opts...)
+	}
+}
 
-	datastore, err := datastore.New(logger, zip.New(dataPath))
+// New is the application constructor. The application serves only
+// healthchecks until data is loaded with Load.
+func New(log *logger.Logger, dataPath string, opts ...Option) *Application {
+	o := &options{}
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	dataOptions, err := zip.New(dataPath).Options()
 	if err != nil {
-		return nil, err
+		log.Error("can't read dataset options: %s", err)
 	}
 
+	progress := datastore.NewProgress()
+	webOpts := append(o.web, web.WithProgress(progress), web.WithExportOptions(dataOptions))
 	return &Application{
-		web:       web.New(logger, datastore),
-		datastore: datastore,
-	}, nil
+		log:         log,
+		dataPath:    dataPath,
+		dataOptions: dataOptions,
+		opts:        o,
+		progress:    progress,
+		web:         web.New(log, nil, webOpts...),
+	}
+}
+
+// Load imports data and makes the application ready to serve requests.
+func (a *Application) Load() error {
+	opts := []datastore.Option{datastore.WithProgress(a.progress)}
+	if a.dataOptions != nil {
+		now, err := datasetNow(a.dataOptions)
+		if err != nil {
+			a.log.Error("can't read dataset time: %s", err)
+		} else {
+			opts = append(opts, datastore.WithNow(now))
+		}
+	}
+	opts = append(opts, a.opts.datastore...)
+	datastore, err := datastore.New(a.log, zip.New(a.dataPath), opts...)
+	if err != nil {
+		return err
+	}
+
+	a.mu.Lock()
+	a.datastore = datastore
+	a.mu.Unlock()
+
+	a.web.SetDatastore(datastore)
+	return nil
+}
+
+// Shutdown stops accepting connections, waits up to timeout for in-flight
+// requests and closes the datastore persisting pending writes if it is
+// loaded. The datastore is closed even if requests were not drained.
+func (a *Application) Shutdown(timeout time.Duration) error {
+	webErr := a.web.Shutdown(timeout)
+	if webErr != nil {
+		a.log.Error("can't drain connections: %s", webErr)
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.datastore != nil {
+		if err := a.datastore.Close(); err != nil {
+			return err
+		}
+	}
+	return webErr
 }
 
 // ListenAndServe starts the server.
@@ -33,7 +131,35 @@ func (a *Application) ListenAndServe(addr string) error {
 	return a.web.ListenAndServe(addr)
 }
 
+// Serve serves requests from a listener.
+func (a *Application) Serve(ln net.Listener) error {
+	return a.web.Serve(ln)
+}
+
 // ListenAndServeProfile starts the server.
 func (a *Application) ListenAndServeProfile(addr string) error {
 	return a.web.ListenAndServeProfile(addr)
 }
+
+// Export writes all accounts and dataset options to w as a zip archive.
+// It fails with ErrNotLoaded until data is loaded.
+func (a *Application) Export(w io.Writer) error {
+	a.mu.Lock()
+	d := a.datastore
+	a.mu.Unlock()
+
+	if d == nil {
+		return ErrNotLoaded
+	}
+	return exportzip.New(w, exportzip.WithOptions(a.dataOptions)).Write(d.Accounts())
+}
+
+// datasetNow parses a current unix timestamp of the dataset,
+// the first line of options.txt.
+func datasetNow(options []byte) (int64, error) {
+	line := options
+	if i := bytes.IndexByte(options, '\n'); i >= 0 {
+		line = options[:i]
+	}
+	return strconv.ParseInt(string(bytes.TrimSpace(line)), 10, 64)
+}
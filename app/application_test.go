@@ -0,0 +1,215 @@
+package app_test
+
+import (
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+
+	"github.com/ngalayko/highloadcup/app"
+	"github.com/ngalayko/highloadcup/app/accounts"
+	"github.com/ngalayko/highloadcup/app/apptest"
+	exportzip "github.com/ngalayko/highloadcup/app/exporter/zip"
+	"github.com/ngalayko/highloadcup/app/generator"
+	"github.com/ngalayko/highloadcup/app/logger"
+)
+
+const (
+	dataPath    = "../test_data/data/data.zip"
+	answersPath = "../test_data/answers/phase_1_get.answ"
+	ammoPath    = "../test_data/ammo/phase_1_get.ammo"
+)
+
+// notServed matches endpoints the app doesn't serve yet, their answers
+// pass only if the expected status is 404.
+var notServed = regexp.MustCompile(`^/accounts/\d+/(recommend|suggest)/`)
+
+func TestPhase1Answers(t *testing.T) {
+	if _, err := os.Stat(dataPath); err != nil {
+		t.Skipf("no test data: %s", err)
+	}
+
+	s, err := apptest.New(dataPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	aa, err := apptest.ParseAnswers(answersPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := apptest.AttachBodies(aa, ammoPath); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, a := range aa {
+		err := s.Check(a)
+		if !notServed.MatchString(a.URI) || a.StatusCode == http.StatusNotFound {
+			if err != nil {
+				t.Error(err)
+			}
+			continue
+		}
+		if err == nil {
+			t.Errorf("%s %s: expected a not served endpoint to fail, update notServed", a.Method, a.URI)
+		}
+	}
+}
+
+func TestExport(t *testing.T) {
+	log.SetOutput(ioutil.Discard)
+
+	aa := generator.New(&generator.Config{
+		Accounts: 100,
+		Seed:     1,
+		Now:      generatedNow,
+	}).Generate()
+	path := writeGenerated(t, aa)
+	options := []byte("1545267613\n0\n")
+
+	if err := app.New(logger.New(), path).Export(&bytes.Buffer{}); err != app.ErrNotLoaded {
+		t.Fatalf("expected export before load to fail with %s, got %v", app.ErrNotLoaded, err)
+	}
+
+	// exported data is loaded from another directory without options.txt.
+	exported, err := export(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	exportedPath := filepath.Join(t.TempDir(), "data.zip")
+	if err := ioutil.WriteFile(exportedPath, exported, 0644); err != nil {
+		t.Fatal(err)
+	}
+	reexported, err := export(exportedPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, name := range []string{"accounts_1.json", "options.txt"} {
+		expected, err := unzip(exported, name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := unzip(reexported, name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(expected, got) {
+			t.Fatalf("%s differs after export round-trip", name)
+		}
+	}
+	if got, _ := unzip(exported, "options.txt"); !bytes.Equal(got, options) {
+		t.Fatalf("expected options %q, got %q", options, got)
+	}
+}
+
+// generatedNow is the current time of generated datasets.
+const generatedNow = 1545267613
+
+// writeGenerated writes accounts to a data zip with options.txt next to it
+// and returns the data zip path.
+func writeGenerated(t *testing.T, aa []*accounts.Account) string {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "data.zip")
+	file, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	if err := exportzip.New(file).Write(aa); err != nil {
+		t.Fatal(err)
+	}
+
+	options := fmt.Sprintf("%d\n0\n", generatedNow)
+	if err := ioutil.WriteFile(filepath.Join(dir, "options.txt"), []byte(options), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// TestGeneratedAnswers serves a generated dataset and checks filter and group
+// responses against answers the generator computes with the oracle.
+func TestGeneratedAnswers(t *testing.T) {
+	log.SetOutput(ioutil.Discard)
+
+	aa := generator.New(&generator.Config{
+		Accounts: 1000,
+		Seed:     1,
+		Now:      generatedNow,
+	}).Generate()
+	s, err := apptest.New(writeGenerated(t, aa))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	g := generator.NewQueryGenerator(aa, generatedNow, 1)
+	qq := []*generator.Query{}
+	for i := 0; i < 500; i++ {
+		qq = append(qq, g.Filter(), g.Group())
+	}
+
+	// answers go through the file format, the same way the tester reads them.
+	answers := &bytes.Buffer{}
+	if err := generator.WriteAnswers(answers, qq); err != nil {
+		t.Fatal(err)
+	}
+	answersPath := filepath.Join(t.TempDir(), "answers.answ")
+	if err := ioutil.WriteFile(answersPath, answers.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+	parsed, err := apptest.ParseAnswers(answersPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(parsed) != len(qq) {
+		t.Fatalf("expected %d answers, got %d", len(qq), len(parsed))
+	}
+
+	for _, a := range parsed {
+		if err := s.Check(a); err != nil {
+			t.Error(err)
+		}
+	}
+}
+
+// export loads data from path and exports it.
+func export(path string) ([]byte, error) {
+	a := app.New(logger.New(), path)
+	if err := a.Load(); err != nil {
+		return nil, err
+	}
+
+	buf := &bytes.Buffer{}
+	if err := a.Export(buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// unzip returns a file from a zip archive.
+func unzip(data []byte, name string) ([]byte, error) {
+	r, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, err
+	}
+	for _, f := range r.File {
+		if f.Name != name {
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return nil, err
+		}
+		defer rc.Close()
+		return ioutil.ReadAll(rc)
+	}
+	return nil, os.ErrNotExist
+}
@@ -0,0 +1,170 @@
+// Package apptest runs the application in-process for tests.
+package apptest
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/valyala/fasthttp"
+	"github.com/valyala/fasthttp/fasthttputil"
+
+	"github.com/ngalayko/highloadcup/app"
+	"github.com/ngalayko/highloadcup/app/ammo"
+	"github.com/ngalayko/highloadcup/app/logger"
+)
+
+// Server is an application served over an in-memory listener.
+type Server struct {
+	ln     *fasthttputil.InmemoryListener
+	client *fasthttp.Client
+}
+
+// New builds an application from a data zip and starts serving it.
+func New(dataPath string) (*Server, error) {
+	a := app.New(logger.New(logger.WithLevel(logger.ErrorLevel)), dataPath)
+	if err := a.Load(); err != nil {
+		return nil, err
+	}
+
+	ln := fasthttputil.NewInmemoryListener()
+	go a.Serve(ln)
+
+	return &Server{
+		ln: ln,
+		client: &fasthttp.Client{
+			Dial: func(string) (net.Conn, error) {
+				return ln.Dial()
+			},
+		},
+	}, nil
+}
+
+// Close stops the server.
+func (s *Server) Close() error {
+	return s.ln.Close()
+}
+
+// Do sends a request and returns response status code and body.
+func (s *Server) Do(method string, uri string, body []byte) (int, []byte, error) {
+	req := fasthttp.AcquireRequest()
+	defer fasthttp.ReleaseRequest(req)
+	resp := fasthttp.AcquireResponse()
+	defer fasthttp.ReleaseResponse(resp)
+
+	req.Header.SetMethod(method)
+	req.SetRequestURI("http://app" + uri)
+	if len(body) != 0 {
+		req.Header.SetContentType("application/json")
+		req.SetBody(body)
+	}
+
+	if err := s.client.Do(req, resp); err != nil {
+		return 0, nil, err
+	}
+	return resp.StatusCode(), append([]byte(nil), resp.Body()...), nil
+}
+
+// Answer is an expected response to a request.
+type Answer struct {
+	Method     string
+	URI        string
+	StatusCode int
+	Response   interface{}
+	Body       []byte
+}
+
+// ParseAnswers parses tab separated answers file lines:
+// method, uri, status code and an optional json response.
+func ParseAnswers(path string) ([]*Answer, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	aa := []*Answer{}
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		parts := strings.SplitN(scanner.Text(), "\t", 4)
+		if len(parts) < 3 {
+			return nil, fmt.Errorf("invalid answer line %d", len(aa)+1)
+		}
+
+		a := &Answer{
+			Method: parts[0],
+			URI:    parts[1],
+		}
+		if a.StatusCode, err = strconv.Atoi(parts[2]); err != nil {
+			return nil, fmt.Errorf("invalid answer line %d: %s", len(aa)+1, err)
+		}
+		if len(parts) == 4 {
+			if a.Response, err = decode([]byte(parts[3])); err != nil {
+				return nil, fmt.Errorf("invalid answer line %d: %s", len(aa)+1, err)
+			}
+		}
+
+		aa = append(aa, a)
+	}
+	return aa, scanner.Err()
+}
+
+// AttachBodies sets answer request bodies from an ammo file.
+func AttachBodies(aa []*Answer, ammoPath string) error {
+	rr, err := ammo.Parse(ammoPath)
+	if err != nil {
+		return err
+	}
+
+	bodies := make(map[string][]byte, len(rr))
+	for _, r := range rr {
+		bodies[r.Method+" "+r.URI] = r.Body
+	}
+	for _, a := range aa {
+		a.Body = bodies[a.Method+" "+a.URI]
+	}
+	return nil
+}
+
+// Check sends the answer request and returns an error
+// if the response doesn't match the answer.
+func (s *Server) Check(a *Answer) error {
+	statusCode, body, err := s.Do(a.Method, a.URI, a.Body)
+	if err != nil {
+		return err
+	}
+
+	if statusCode != a.StatusCode {
+		return fmt.Errorf("%s %s: expected status %d, got %d", a.Method, a.URI, a.StatusCode, statusCode)
+	}
+
+	if a.Response == nil {
+		return nil
+	}
+
+	got, err := decode(body)
+	if err != nil {
+		return fmt.Errorf("%s %s: can't parse response: %s", a.Method, a.URI, err)
+	}
+	if !reflect.DeepEqual(a.Response, got) {
+		return fmt.Errorf("%s %s: unexpected response %s", a.Method, a.URI, body)
+	}
+	return nil
+}
+
+// decode decodes json keeping numbers as json.Number.
+func decode(data []byte) (interface{}, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+
+	var v interface{}
+	return v, dec.Decode(&v)
+}
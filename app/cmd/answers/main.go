@@ -0,0 +1,96 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/ngalayko/highloadcup/app/accounts"
+	"github.com/ngalayko/highloadcup/app/generator"
+	"github.com/ngalayko/highloadcup/app/importer/zip"
+)
+
+var (
+	dataPath    = flag.String("data_path", "data/data.zip", "path to a dataset to generate queries for")
+	optionsPath = flag.String("options_path", "", "path to options.txt of the dataset, next to data_path by default")
+	outPath     = flag.String("out_path", "answers", "directory to write answers and ammo to")
+	name        = flag.String("name", "phase_1_get", "name of answers and ammo files")
+	queries     = flag.Int("queries", 3000, "number of queries to generate")
+	seed        = flag.Int64("seed", 1, "random seed, the same seed generates the same queries")
+)
+
+func main() {
+	flag.Parse()
+
+	if *optionsPath == "" {
+		*optionsPath = filepath.Join(filepath.Dir(*dataPath), "options.txt")
+	}
+	now, err := readNow(*optionsPath)
+	if err != nil {
+		log.Panic(err.Error())
+	}
+
+	data, err := zip.New(*dataPath).Read()
+	if err != nil {
+		log.Panic(err.Error())
+	}
+	aa, err := accounts.Parse(data...)
+	if err != nil {
+		log.Panic(err.Error())
+	}
+	if len(aa) == 0 {
+		log.Panicf("no accounts in %s", *dataPath)
+	}
+
+	qq := generator.NewQueryGenerator(aa, now, *seed).Generate(*queries)
+
+	if err := os.MkdirAll(*outPath, 0755); err != nil {
+		log.Panic(err.Error())
+	}
+	if err := write(filepath.Join(*outPath, *name+".answ"), qq, generator.WriteAnswers); err != nil {
+		log.Panic(err.Error())
+	}
+	if err := write(filepath.Join(*outPath, *name+".ammo"), qq, generator.WriteAmmo); err != nil {
+		log.Panic(err.Error())
+	}
+
+	log.Printf("generated %d queries for %d accounts to %s", len(qq), len(aa), *outPath)
+}
+
+// readNow reads the current timestamp of a dataset, the first line of options.txt.
+func readNow(path string) (int64, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	if !scanner.Scan() {
+		return 0, fmt.Errorf("%s is empty", path)
+	}
+	return strconv.ParseInt(strings.TrimSpace(scanner.Text()), 10, 64)
+}
+
+func write(path string, qq []*generator.Query, writeFunc func(io.Writer, []*generator.Query) error) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	w := bufio.NewWriter(file)
+	if err := writeFunc(w, qq); err != nil {
+		return err
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	return file.Close()
+}
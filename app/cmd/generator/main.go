@@ -0,0 +1,56 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+
+	exportzip "github.com/ngalayko/highloadcup/app/exporter/zip"
+	"github.com/ngalayko/highloadcup/app/generator"
+)
+
+var (
+	outPath  = flag.String("out_path", "data", "directory to write data.zip and options.txt to")
+	accounts = flag.Int("accounts", 10000, "number of accounts to generate")
+	seed     = flag.Int64("seed", 1, "random seed, the same seed generates the same data")
+	now      = flag.Int64("now", 1545267613, "current timestamp of the dataset, written to options.txt")
+	rating   = flag.Bool("rating", false, "mark dataset as a rating one in options.txt")
+)
+
+func main() {
+	flag.Parse()
+
+	if err := os.MkdirAll(*outPath, 0755); err != nil {
+		log.Panic(err.Error())
+	}
+
+	aa := generator.New(&generator.Config{
+		Accounts: *accounts,
+		Seed:     *seed,
+		Now:      *now,
+	}).Generate()
+
+	file, err := os.Create(filepath.Join(*outPath, "data.zip"))
+	if err != nil {
+		log.Panic(err.Error())
+	}
+	defer file.Close()
+
+	if err := exportzip.New(file).Write(aa); err != nil {
+		log.Panic(err.Error())
+	}
+
+	mode := 0
+	if *rating {
+		mode = 1
+	}
+	options := fmt.Sprintf("%d\n%d\n", *now, mode)
+	if err := ioutil.WriteFile(filepath.Join(*outPath, "options.txt"), []byte(options), 0644); err != nil {
+		log.Panic(err.Error())
+	}
+
+	log.Printf("generated %d accounts to %s", len(aa), *outPath)
+}
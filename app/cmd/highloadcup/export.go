@@ -0,0 +1,34 @@
+package main
+
+import (
+	"flag"
+	"log"
+	"os"
+
+	"github.com/ngalayko/highloadcup/app"
+)
+
+var exportPath = flag.String("export_path", "data.zip", "path to write exported data to, used by export command")
+
+// export writes current dataset to a zip archive.
+func export() {
+	l := newLogger()
+	defer l.Close()
+
+	a := app.New(l, *dataPath, app.WithDatastore(options()...))
+	if err := a.Load(); err != nil {
+		log.Panic(err.Error())
+	}
+
+	file, err := os.Create(*exportPath)
+	if err != nil {
+		log.Panic(err.Error())
+	}
+	defer file.Close()
+
+	if err := a.Export(file); err != nil {
+		log.Panic(err.Error())
+	}
+
+	log.Printf("exported data to %s", *exportPath)
+}
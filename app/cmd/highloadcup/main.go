@@ -3,23 +3,67 @@ package main
 import (
 	"flag"
 	"log"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"github.com/ngalayko/highloadcup/app"
+	"github.com/ngalayko/highloadcup/app/datastore"
+	"github.com/ngalayko/highloadcup/app/logger"
+	"github.com/ngalayko/highloadcup/app/web"
 )
 
 var (
-	dataPath    = flag.String("data_path", "", "path to initial data")
-	listenAddr  = flag.String("addr", ":80", "addr to listen")
-	profileAddr = flag.String("profile_addr", "", "enable profile")
+	dataPath     = flag.String("data_path", "", "path to initial data")
+	listenAddr   = flag.String("addr", ":80", "addr to listen")
+	profileAddr  = flag.String("profile_addr", "", "addr to serve profiles, metrics and admin endpoints, disabled if empty")
+	snapshotPath = flag.String("snapshot_path", "", "path to a datastore snapshot")
+	walPath      = flag.String("wal_path", "", "path to a write-ahead log of accepted writes")
+	walSync      = flag.String("wal_sync", "always", "write-ahead log sync policy: always, never or a sync interval")
+	rebuildDelay = flag.Duration("rebuild_delay", time.Second, "rebuild indexes in background once writes were quiet for a duration")
+	logLevel     = flag.String("log_level", "info", "log level: debug, info, error or off")
+	logAsync     = flag.Int("log_async", 0, "write logs in background buffering up to a number of entries, 0 to write synchronously")
+	logRequests  = flag.Uint64("log_requests", 0, "log every n-th request, 0 to disable request logs")
+	slowQuery    = flag.Duration("slow_query", 0, "log filter and group queries slower than a duration with their plans, 0 to disable")
+	slowLogPath  = flag.String("slow_log", "", "path to a slow query log, the main log is used by default")
+	explain      = flag.Bool("explain", false, "enable explain=1 parameter returning plans of filter and group queries")
+	shutdown     = flag.Duration("shutdown_timeout", 5*time.Second, "time to wait for in-flight requests on SIGINT or SIGTERM")
 )
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "export" {
+		flag.CommandLine.Parse(os.Args[2:])
+		export()
+		return
+	}
+
 	flag.Parse()
 
-	a, err := app.New(*dataPath)
-	if err != nil {
-		log.Panic(err.Error())
+	if err := run(); err != nil {
+		os.Exit(1)
 	}
+}
+
+// run serves the application until a signal, a server error or a data load
+// error and shuts it down. The returned error is already logged.
+func run() error {
+	l := newLogger()
+	defer l.Close()
+
+	webOpts, closeWeb := webOptions(l)
+	defer closeWeb()
+
+	a := app.New(l, *dataPath, app.WithDatastore(options()...), app.WithWeb(webOpts...))
+
+	// the server is started while data is loading to serve healthchecks,
+	// readiness reports a load error until the application is shut down.
+	loadFailed := make(chan error, 1)
+	go func() {
+		if err := a.Load(); err != nil {
+			loadFailed <- err
+		}
+	}()
 
 	go func() {
 		if *profileAddr == "" {
@@ -27,11 +71,87 @@ func main() {
 		}
 
 		if err := a.ListenAndServeProfile(*profileAddr); err != nil {
-			log.Printf("profile server stopped with error: %s", err)
+			l.Error("profile server stopped with error: %s", err)
 		}
 	}()
 
-	if err := a.ListenAndServe(*listenAddr); err != nil {
-		log.Printf("web server stopped with error: %s", err)
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+
+	stopped := make(chan error, 1)
+	go func() {
+		stopped <- a.ListenAndServe(*listenAddr)
+	}()
+
+	var err error
+	select {
+	case sig := <-signals:
+		l.Info("received %s, shutting down", sig)
+	case err = <-stopped:
+		l.Error("web server stopped with error: %s", err)
+	case err = <-loadFailed:
+		l.Error("can't load data: %s", err)
+	}
+
+	if shutdownErr := a.Shutdown(*shutdown); shutdownErr != nil {
+		l.Error("can't shut down: %s", shutdownErr)
+		err = shutdownErr
+	}
+	l.Info("stopped")
+	return err
+}
+
+func newLogger() *logger.Logger {
+	level, err := logger.ParseLevel(*logLevel)
+	if err != nil {
+		log.Panic(err.Error())
+	}
+	return logger.New(
+		logger.WithLevel(level),
+		logger.WithAsync(*logAsync),
+		logger.WithRequestSampling(*logRequests),
+	)
+}
+
+// webOptions returns web options and a function closing opened logs.
+func webOptions(l *logger.Logger) ([]web.Option, func()) {
+	opts := []web.Option{}
+	if *explain {
+		opts = append(opts, web.WithExplain())
+	}
+
+	if *slowQuery <= 0 {
+		return opts, func() {}
+	}
+
+	if *slowLogPath == "" {
+		return append(opts, web.WithSlowLog(l, *slowQuery)), func() {}
+	}
+
+	file, err := os.OpenFile(*slowLogPath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		log.Panic(err.Error())
+	}
+	slow := logger.New(logger.WithOutput(file), logger.WithAsync(*logAsync))
+	return append(opts, web.WithSlowLog(slow, *slowQuery)), func() {
+		slow.Close()
+		file.Close()
+	}
+}
+
+func options() []datastore.Option {
+	opts := []datastore.Option{
+		datastore.WithRebuildDelay(*rebuildDelay),
+	}
+	if *snapshotPath != "" {
+		opts = append(opts, datastore.WithSnapshot(*snapshotPath))
+	}
+	if *walPath != "" {
+		policy, err := datastore.ParseSyncPolicy(*walSync)
+		if err != nil {
+			log.Panic(err.Error())
+		}
+		opts = append(opts, datastore.WithWAL(*walPath, policy))
 	}
+	return opts
 }
@@ -40,7 +40,7 @@ func Year(ys string) (CompareDatesFunc, error) {
 		return nil, err
 	}
 	return func(t2 time.Time) bool {
-		return t2.Year() == y
+		return t2.UTC().Year() == y
 	}, nil
 }
 
@@ -50,11 +50,12 @@ type CompareFunc func(string) bool
 // Code return code predicate.
 func Code(code string) CompareFunc {
 	return func(v string) bool {
-		index := strings.Index(v, "(")
-		if index < 0 {
+		from := strings.Index(v, "(")
+		to := strings.Index(v, ")")
+		if from < 0 || to < from {
 			return false
 		}
-		return strings.HasPrefix(v[index+1:], code)
+		return v[from+1:to] == code
 	}
 }
 
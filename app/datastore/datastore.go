@@ -3,6 +3,8 @@ package datastore
 import (
 	"fmt"
 	"sort"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/ngalayko/highloadcup/app/accounts"
@@ -15,143 +17,280 @@ type Datastore struct {
 	log      *logger.Logger
 	importer importer.Importer
 
-	ordered      []*accounts.Account
-	byID         map[int64]*accounts.Account
-	bySex        map[string][]*accounts.Account
-	byEmail      map[string]*accounts.Account
-	byStatus     map[string][]*accounts.Account
-	byFName      map[string][]*accounts.Account
-	bySName      map[string][]*accounts.Account
-	byPhone      map[string]*accounts.Account
-	byCountry    map[string][]*accounts.Account
-	byCity       map[string][]*accounts.Account
-	byBirth      map[time.Time][]*accounts.Account
-	byJoin       map[time.Time][]*accounts.Account
-	byInterest   map[string][]*accounts.Account
-	likedBy      map[string][]*accounts.Account
-	premiumStart map[time.Time][]*accounts.Account
-	premiumEnd   map[time.Time][]*accounts.Account
-	premium      []*accounts.Account
-	noPremium    []*accounts.Account
+	// rebuildDelay defers index rebuild until there were no writes
+	// for a duration.
+	rebuildDelay time.Duration
+	// now is a unix timestamp premium_now is checked against.
+	now int64
+
+	mu      sync.Mutex
+	byID    map[int64]*accounts.Account
+	emails  map[string]int64
+	pending []*Mutation
+	rebuild *time.Timer
+	// seq is a sequence number of the last applied mutation.
+	seq uint64
+	wal *wal
+	// closed rejects writes once the datastore is closed.
+	closed bool
+
+	snapshotPath string
+	// source is imported data saved snapshots are built from.
+	source  source
+	walPath string
+	walSync SyncPolicy
+
+	rebuildMu sync.Mutex
+	idx       atomic.Value
+
+	// snapshotMu serializes snapshot writes, savedSeq is a sequence
+	// number of the last saved snapshot.
+	snapshotMu sync.Mutex
+	savedSeq   uint64
+
+	progress *Progress
+}
+
+// Option is a datastore option.
+type Option func(*Datastore)
+
+// defaultRebuildDelay is a quiet duration indexes are rebuilt after.
+const defaultRebuildDelay = time.Second
+
+// WithRebuildDelay sets a quiet duration indexes are rebuilt after. Writes
+// are always applied only to primary account records and the pending log,
+// secondary indexes are rebuilt in the background once there were no
+// writes for a delay, a second by default.
+func WithRebuildDelay(delay time.Duration) Option {
+	return func(d *Datastore) {
+		d.rebuildDelay = delay
+	}
+}
+
+// WithNow sets a current unix timestamp of the dataset, active premiums
+// are checked against it. The time of a query is used by default.
+func WithNow(now int64) Option {
+	return func(d *Datastore) {
+		d.now = now
+	}
 }
 
 // New is a datastore constructor.
-func New(log *logger.Logger, i importer.Importer) (*Datastore, error) {
+func New(log *logger.Logger, i importer.Importer, opts ...Option) (*Datastore, error) {
 	d := &Datastore{
 		importer:     i,
 		log:          log,
 		byID:         map[int64]*accounts.Account{},
-		bySex:        map[string][]*accounts.Account{},
-		byEmail:      map[string]*accounts.Account{},
-		byStatus:     map[string][]*accounts.Account{},
-		byFName:      map[string][]*accounts.Account{},
-		bySName:      map[string][]*accounts.Account{},
-		byPhone:      map[string]*accounts.Account{},
-		byCountry:    map[string][]*accounts.Account{},
-		byCity:       map[string][]*accounts.Account{},
-		byBirth:      map[time.Time][]*accounts.Account{},
-		byJoin:       map[time.Time][]*accounts.Account{},
-		byInterest:   map[string][]*accounts.Account{},
-		likedBy:      map[string][]*accounts.Account{},
-		premiumStart: map[time.Time][]*accounts.Account{},
-		premiumEnd:   map[time.Time][]*accounts.Account{},
+		emails:       map[string]int64{},
+		rebuildDelay: defaultRebuildDelay,
+	}
+	for _, opt := range opts {
+		opt(d)
 	}
 	if err := d.init(); err != nil {
+		d.progress.fail(err)
 		return nil, err
 	}
-	sort.Slice(d.ordered, func(i, j int) bool {
-		return d.ordered[i].ID > d.ordered[j].ID
-	})
+	d.progress.stage(StageReady)
 	return d, nil
 }
 
 func (d *Datastore) init() error {
-	data, err := d.importer.Read()
+	aa, err := d.load()
 	if err != nil {
-		return fmt.Errorf("can't read test data: %s", err)
-	}
-
-	aa, err := accounts.Parse(data...)
-	if err != nil {
-		return fmt.Errorf("can't parse test data: %s", err)
+		return err
 	}
 
 	d.log.Info("loaded %d accounts", len(aa))
 
-	d.ordered = aa
 	for _, a := range aa {
-		d.saveAccount(a)
-	}
-
-	d.log.Info("byID: %d", len(d.byID))
-	d.log.Info("bySex: %d", len(d.bySex))
-	d.log.Info("byEmail: %d", len(d.byEmail))
-	d.log.Info("byStatus: %d", len(d.byStatus))
-	d.log.Info("byFName: %d", len(d.byFName))
-	d.log.Info("bySName: %d", len(d.bySName))
-	d.log.Info("byPhone: %d", len(d.byPhone))
-	d.log.Info("byCountry: %d", len(d.byCountry))
-	d.log.Info("byCity: %d", len(d.byCity))
-	d.log.Info("byBirth: %d", len(d.byBirth))
-	d.log.Info("byJoind: %d", len(d.byJoin))
-	d.log.Info("byInterest: %d", len(d.byInterest))
-	d.log.Info("likedBy: %d", len(d.likedBy))
-	d.log.Info("premiumStart: %d", len(d.premiumStart))
-	d.log.Info("premiumEnd: %d", len(d.premiumEnd))
+		prepare(a)
+		d.byID[a.ID] = a
+		d.emails[a.Email] = a.ID
+	}
+
+	d.progress.loaded(len(d.byID))
+
+	if d.walPath != "" {
+		d.progress.stage(StageReplaying)
+		replayed, size, err := d.replay()
+		if err != nil {
+			return err
+		}
+		d.log.Info("replayed %d mutations from %s", replayed, d.walPath)
+
+		if replayed != 0 {
+			aa = make([]*accounts.Account, 0, len(d.byID))
+			for _, a := range d.byID {
+				aa = append(aa, a)
+			}
+			d.snapshotMu.Lock()
+			d.saveSnapshot(aa, d.seq)
+			d.snapshotMu.Unlock()
+		}
+
+		d.wal, err = openWAL(d.walPath, d.walSync, size)
+		if err != nil {
+			return fmt.Errorf("can't open write-ahead log: %s", err)
+		}
+		d.compactWAL(d.wal, d.savedSeq)
+	}
+
+	d.progress.loaded(len(d.byID))
+	d.progress.stage(StageIndexing)
+	idx := newIndex(aa)
+	d.idx.Store(idx)
+
+	stats := d.Stats()
+	d.log.Info("byID: %d", stats.Accounts)
+	names := make([]string, 0, len(stats.Buckets))
+	for name := range stats.Buckets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		d.log.Info("%s: %d", name, stats.Buckets[name])
+	}
 
 	return nil
 }
 
-func (d *Datastore) saveAccount(a *accounts.Account) {
-	a.InterestsMap = make(map[string]bool, len(a.Interests))
-	for _, i := range a.Interests {
-		a.InterestsMap[i] = true
+// load reads accounts from a fresh snapshot or from the importer.
+func (d *Datastore) load() ([]*accounts.Account, error) {
+	if d.snapshotPath != "" {
+		src, err := d.importedSource()
+		if err != nil {
+			return nil, fmt.Errorf("can't check imported data: %s", err)
+		}
+		d.source = src
+
+		fresh, err := d.snapshotFresh()
+		if err != nil {
+			return nil, fmt.Errorf("can't check snapshot: %s", err)
+		}
+		if fresh {
+			d.progress.stage(StageSnapshot)
+			aa, seq, err := readSnapshot(d.snapshotPath)
+			if err == nil {
+				d.log.Info("loaded snapshot %s at mutation %d", d.snapshotPath, seq)
+				d.seq = seq
+				d.savedSeq = seq
+				return aa, nil
+			}
+			d.log.Error("can't read snapshot, importing data: %s", err)
+		}
 	}
 
-	d.byID[a.ID] = a
+	d.progress.stage(StageImporting)
+	data, err := d.importer.Read()
+	if err != nil {
+		return nil, fmt.Errorf("can't read test data: %s", err)
+	}
 
-	d.bySex[a.Sex] = append(d.bySex[a.Sex], a)
+	d.progress.files(len(data))
+	aa := []*accounts.Account{}
+	for _, r := range data {
+		parsed, err := accounts.Parse(r)
+		if err != nil {
+			return nil, fmt.Errorf("can't parse test data: %s", err)
+		}
+		aa = append(aa, parsed...)
+		d.progress.parsed(len(parsed))
+	}
 
-	d.byEmail[a.Email] = a
-	d.byStatus[a.Status] = append(d.byStatus[a.Status], a)
-	d.byFName[a.FName] = append(d.byFName[a.FName], a)
-	d.bySName[a.SName] = append(d.bySName[a.SName], a)
-	d.byPhone[a.Phone] = a
-	d.byCountry[a.Country] = append(d.byCountry[a.Country], a)
-	d.byCity[a.City] = append(d.byCity[a.City], a)
+	d.snapshotMu.Lock()
+	d.saveSnapshot(aa, 0)
+	d.snapshotMu.Unlock()
 
-	birth := time.Unix(a.Birth, 0)
-	d.byBirth[birth] = append(d.byBirth[birth], a)
+	return aa, nil
+}
 
-	join := time.Unix(a.Joined, 0)
-	d.byJoin[join] = append(d.byJoin[join], a)
+// index returns current secondary indexes.
+func (d *Datastore) index() *index {
+	return d.idx.Load().(*index)
+}
 
-	for _, i := range a.Interests {
-		d.byInterest[i] = append(d.byInterest[i], a)
+// scheduleRebuild postpones index rebuild until there were no writes
+// for a rebuildDelay. Must be called with d.mu held.
+func (d *Datastore) scheduleRebuild() {
+	if d.rebuild == nil {
+		d.rebuild = time.AfterFunc(d.rebuildDelay, d.Rebuild)
+		return
 	}
+	d.rebuild.Reset(d.rebuildDelay)
+}
 
-	a.LikesMap = make(map[string]bool, len(a.Likes))
-	for _, like := range a.Likes {
-		sID := fmt.Sprint(like.ID)
-		d.likedBy[sID] = append(d.likedBy[sID], a)
-		a.LikesMap[sID] = true
-	}
+// Rebuild builds secondary indexes from primary records, atomically swaps
+// them in, compacts the pending log and saves a snapshot if it is enabled.
+func (d *Datastore) Rebuild() {
+	d.rebuildMu.Lock()
 
-	if a.Premium == nil {
-		d.noPremium = append(d.noPremium, a)
+	d.mu.Lock()
+	applied := len(d.pending)
+	if applied == 0 {
+		d.mu.Unlock()
+		d.rebuildMu.Unlock()
 		return
 	}
+	seq := d.seq
+	aa := make([]*accounts.Account, 0, len(d.byID))
+	for _, a := range d.byID {
+		aa = append(aa, a)
+	}
+	d.mu.Unlock()
 
-	pStart := time.Unix(a.Premium.Start, 0)
-	d.premiumStart[pStart] = append(d.premiumStart[pStart], a)
+	start := time.Now()
+	idx := newIndex(aa)
 
-	pEnd := time.Unix(a.Premium.Finish, 0)
-	d.premiumEnd[pEnd] = append(d.premiumEnd[pEnd], a)
+	d.mu.Lock()
+	d.idx.Store(idx)
+	d.pending = d.pending[applied:]
+	d.mu.Unlock()
 
-	d.premium = append(d.premium, a)
+	d.log.Info("indexes rebuilt with %d pending writes in %s", applied, time.Since(start))
+
+	// the next rebuild can start while the snapshot is written.
+	d.snapshotMu.Lock()
+	d.rebuildMu.Unlock()
+	defer d.snapshotMu.Unlock()
+
+	d.saveSnapshot(aa, seq)
+}
+
+// Pending returns number of writes not yet reflected in indexes.
+func (d *Datastore) Pending() int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	return len(d.pending)
 }
 
 // GetAccounts returns all accounts.
 func (d *Datastore) GetAccounts() []*accounts.Account {
-	return d.ordered
+	return d.index().ordered
+}
+
+// Account returns primary account record by id.
+func (d *Datastore) Account(id int64) (*accounts.Account, bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	a, exists := d.byID[id]
+	return a, exists
+}
+
+// Accounts returns all primary account records ordered by id, including
+// writes not yet reflected in indexes.
+func (d *Datastore) Accounts() []*accounts.Account {
+	d.mu.Lock()
+	aa := make([]*accounts.Account, 0, len(d.byID))
+	for _, a := range d.byID {
+		aa = append(aa, a)
+	}
+	d.mu.Unlock()
+
+	sort.Slice(aa, func(i, j int) bool {
+		return aa[i].ID < aa[j].ID
+	})
+	return aa
 }
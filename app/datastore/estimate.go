@@ -0,0 +1,127 @@
+package datastore
+
+import (
+	"strings"
+
+	"github.com/ngalayko/highloadcup/app/accounts"
+)
+
+// Estimate fills estimated numbers of candidates of plan steps from index
+// statistics assuming filters are independent.
+func (d *Datastore) Estimate(plan *Plan) {
+	idx := d.index()
+	total := float64(len(idx.ordered))
+
+	estimated := total
+	for i, s := range plan.Steps {
+		s.Estimated = -1
+		if s.Predicate == "group" {
+			s.Estimated = int(estimated + 0.5)
+			continue
+		}
+
+		n, ok := idx.estimate(s.Predicate)
+		if !ok {
+			continue
+		}
+		if i == 0 {
+			estimated = float64(n)
+		} else if total > 0 {
+			estimated *= float64(n) / total
+		}
+		s.Estimated = int(estimated + 0.5)
+	}
+}
+
+// estimate returns a number of accounts matching a predicate named as
+// key=value parameter, it returns false if there are no statistics for it.
+func (idx *index) estimate(predicate string) (int, bool) {
+	i := strings.IndexByte(predicate, '=')
+	if i < 0 {
+		return 0, false
+	}
+	key, value := predicate[:i], predicate[i+1:]
+
+	field, op := key, "eq"
+	if j := strings.IndexByte(key, '_'); j >= 0 {
+		field, op = key[:j], key[j+1:]
+	}
+
+	switch field {
+	case "sex":
+		return estimateValues(idx.bySex, len(idx.ordered), op, value)
+	case "status":
+		return estimateValues(idx.byStatus, len(idx.ordered), op, value)
+	case "fname":
+		return estimateValues(idx.byFName, len(idx.ordered), op, value)
+	case "sname":
+		return estimateValues(idx.bySName, len(idx.ordered), op, value)
+	case "phone":
+		return estimateValues(idx.byPhone, len(idx.ordered), op, value)
+	case "country":
+		return estimateValues(idx.byCountry, len(idx.ordered), op, value)
+	case "city":
+		return estimateValues(idx.byCity, len(idx.ordered), op, value)
+	case "interests":
+		return estimateSets(idx.byInterest, len(idx.ordered), op, value)
+	case "likes":
+		return estimateSets(idx.likedBy, len(idx.ordered), op, value)
+	case "premium":
+		if op != "null" {
+			return 0, false
+		}
+		if value == "1" {
+			return len(idx.noPremium), true
+		}
+		return len(idx.premium), true
+	}
+	return 0, false
+}
+
+// estimateValues estimates a predicate on a field with a single value.
+func estimateValues(index map[string][]*accounts.Account, total int, op string, value string) (int, bool) {
+	switch op {
+	case "eq":
+		return len(index[value]), true
+	case "neq":
+		return total - len(index[value]), true
+	case "any":
+		n := 0
+		for _, v := range strings.Split(value, ",") {
+			n += len(index[v])
+		}
+		return n, true
+	case "null":
+		if value == "1" {
+			return len(index[""]), true
+		}
+		return total - len(index[""]), true
+	}
+	return 0, false
+}
+
+// estimateSets estimates a predicate on a field with a set of values:
+// contains is at most the smallest of values, any is at most their sum.
+func estimateSets(index map[string][]*accounts.Account, total int, op string, value string) (int, bool) {
+	vv := strings.Split(value, ",")
+	switch op {
+	case "eq", "contains":
+		n := total
+		for _, v := range vv {
+			if len(index[v]) < n {
+				n = len(index[v])
+			}
+		}
+		return n, true
+	case "any":
+		n := 0
+		for _, v := range vv {
+			n += len(index[v])
+		}
+		if n > total {
+			n = total
+		}
+		return n, true
+	}
+	return 0, false
+}
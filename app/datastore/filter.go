@@ -2,7 +2,7 @@ package datastore
 
 import (
 	"bytes"
-	"strconv"
+	"fmt"
 	"time"
 
 	"github.com/ngalayko/highloadcup/app/accounts"
@@ -10,36 +10,50 @@ import (
 
 // FilterAccounts returns accounts by given filters.
 func (d *Datastore) FilterAccounts(limit int, ff ...FilterFunc) (map[int64]*accounts.Account, error) {
+	return d.FilterAccountsPlan(nil, limit, ff...)
+}
+
+// FilterAccountsPlan returns accounts by given filters recording execution
+// details to a plan if it is not nil.
+func (d *Datastore) FilterAccountsPlan(plan *Plan, limit int, ff ...FilterFunc) (map[int64]*accounts.Account, error) {
+	start := plan.start()
+	defer plan.finish(start)
+
+	idx := d.index()
+
 	res := make(map[int64]*accounts.Account)
 	if len(ff) != 0 {
-		for _, filter := range ff {
-			res = filter(res)
+		for i, filter := range ff {
+			stepStart := plan.start()
+			res = filter(idx, res)
+			plan.step(i, len(res), stepStart)
 			if len(res) == 0 {
 				return nil, nil
 			}
 		}
 		return res, nil
 	}
-	if limit < 0 {
-		limit = len(d.ordered)
+	if limit < 0 || limit > len(idx.ordered) {
+		limit = len(idx.ordered)
 	}
-	for _, a := range d.ordered[:limit] {
+	for _, a := range idx.ordered[:limit] {
 		res[a.ID] = a
 	}
 	return res, nil
 }
 
-// FilterFunc is used to get accounts by a filter.
-type FilterFunc func(map[int64]*accounts.Account) map[int64]*accounts.Account
+// FilterFunc is used to get accounts by a filter. The first filter of
+// a query takes candidates from indexes loaded once for the query.
+type FilterFunc func(*index, map[int64]*accounts.Account) map[int64]*accounts.Account
 
 // FilterPremiumNull filters accounts who have premium.
 func (d *Datastore) FilterPremiumNull(null string) FilterFunc {
 	empty := null == "1"
 
-	getPremuimNull := func(in map[int64]*accounts.Account) map[int64]*accounts.Account {
-		rangeOver := d.premium
+	getPremuimNull := func(idx *index, in map[int64]*accounts.Account) map[int64]*accounts.Account {
+		rangeOver := idx.premium
 		if empty {
-			rangeOver = d.noPremium
+			rangeOver = idx.noPremium
 		}
 
 		for _, a := range rangeOver {
@@ -62,24 +76,27 @@ func (d *Datastore) FilterPremiumNull(null string) FilterFunc {
 		return in
 	}
 
-	return func(in map[int64]*accounts.Account) map[int64]*accounts.Account {
+	return func(idx *index, in map[int64]*accounts.Account) map[int64]*accounts.Account {
 		if len(in) == 0 {
-			return getPremuimNull(in)
+			return getPremuimNull(idx, in)
 		}
 		return filterPremiumNull(in)
 	}
 }
 
-// FilterPremiumNow filters accounts with active premium.
-func (d *Datastore) FilterPremiumNow(ts string) (FilterFunc, error) {
-	tm, err := strconv.ParseInt(ts, 10, 64)
-	if err != nil {
-		return nil, err
+// FilterPremiumNow filters accounts with premium active at the dataset
+// current time, the only valid value is 1.
+func (d *Datastore) FilterPremiumNow(value string) (FilterFunc, error) {
+	if value != "1" {
+		return nil, fmt.Errorf("invalid premium_now value '%s'", value)
+	}
+	now := time.Now()
+	if d.now != 0 {
+		now = time.Unix(d.now, 0)
 	}
-	now := time.Unix(tm, 0)
 
-	getPremiumNow := func(in map[int64]*accounts.Account) map[int64]*accounts.Account {
-		for start, aa := range d.premiumStart {
+	getPremiumNow := func(idx *index, in map[int64]*accounts.Account) map[int64]*accounts.Account {
+		for start, aa := range idx.premiumStart {
 			if start.After(now) {
 				continue
 			}
@@ -112,9 +129,9 @@ func (d *Datastore) FilterPremiumNow(ts string) (FilterFunc, error) {
 		return in
 	}
 
-	return func(in map[int64]*accounts.Account) map[int64]*accounts.Account {
+	return func(idx *index, in map[int64]*accounts.Account) map[int64]*accounts.Account {
 		if len(in) == 0 {
-			return getPremiumNow(in)
+			return getPremiumNow(idx, in)
 		}
 		return filterPremiumNow(in)
 	}, nil
@@ -128,8 +145,8 @@ func (d *Datastore) FilterLikesContains(ll []byte) FilterFunc {
 		likesMap[string(like)] = true
 	}
 
-	getLikesContain := func(in map[int64]*accounts.Account) map[int64]*accounts.Account {
-		for like, aa := range d.likedBy {
+	getLikesContain := func(idx *index, in map[int64]*accounts.Account) map[int64]*accounts.Account {
+		for like, aa := range idx.likedBy {
 			if !likesMap[like] {
 				continue
 			}
@@ -167,9 +184,9 @@ func (d *Datastore) FilterLikesContains(ll []byte) FilterFunc {
 		return in
 	}
 
-	return func(in map[int64]*accounts.Account) map[int64]*accounts.Account {
+	return func(idx *index, in map[int64]*accounts.Account) map[int64]*accounts.Account {
 		if len(in) == 0 {
-			return getLikesContain(in)
+			return getLikesContain(idx, in)
 		}
 		return filterLikesContain(in)
 	}
@@ -179,9 +196,9 @@ func (d *Datastore) FilterLikesContains(ll []byte) FilterFunc {
 func (d *Datastore) FilterInterestsAny(ii []byte) FilterFunc {
 	interests := bytes.Split(ii, []byte(","))
 
-	getByInterestsAny := func(in map[int64]*accounts.Account) map[int64]*accounts.Account {
+	getByInterestsAny := func(idx *index, in map[int64]*accounts.Account) map[int64]*accounts.Account {
 		for _, interest := range interests {
-			for _, a := range d.byInterest[string(interest)] {
+			for _, a := range idx.byInterest[string(interest)] {
 				in[a.ID] = a
 			}
 		}
@@ -196,18 +213,18 @@ func (d *Datastore) FilterInterestsAny(ii []byte) FilterFunc {
 					skip = false
 					break
 				}
-				if !skip {
-					continue
-				}
-				delete(in, id)
 			}
+			if !skip {
+				continue
+			}
+			delete(in, id)
 		}
 		return in
 	}
 
-	return func(in map[int64]*accounts.Account) map[int64]*accounts.Account {
+	return func(idx *index, in map[int64]*accounts.Account) map[int64]*accounts.Account {
 		if len(in) == 0 {
-			return getByInterestsAny(in)
+			return getByInterestsAny(idx, in)
 		}
 		return filterByInterestsAny(in)
 	}
@@ -221,8 +238,8 @@ func (d *Datastore) FilterInterestsContains(ii []byte) FilterFunc {
 		interestMap[string(interest)] = true
 	}
 
-	getByInterestsContain := func(in map[int64]*accounts.Account) map[int64]*accounts.Account {
-		for interest, aa := range d.byInterest {
+	getByInterestsContain := func(idx *index, in map[int64]*accounts.Account) map[int64]*accounts.Account {
+		for interest, aa := range idx.byInterest {
 			if !interestMap[interest] {
 				continue
 			}
@@ -260,9 +277,9 @@ func (d *Datastore) FilterInterestsContains(ii []byte) FilterFunc {
 		return in
 	}
 
-	return func(in map[int64]*accounts.Account) map[int64]*accounts.Account {
+	return func(idx *index, in map[int64]*accounts.Account) map[int64]*accounts.Account {
 		if len(in) == 0 {
-			return getByInterestsContain(in)
+			return getByInterestsContain(idx, in)
 		}
 		return filterByInterestsContain(in)
 	}
@@ -270,8 +287,8 @@ func (d *Datastore) FilterInterestsContains(ii []byte) FilterFunc {
 
 // FilterJoined filters accounts with birth matching a function.
 func (d *Datastore) FilterJoined(compare CompareDatesFunc) FilterFunc {
-	getByJoin := func(in map[int64]*accounts.Account) map[int64]*accounts.Account {
-		for join, aa := range d.byJoin {
+	getByJoin := func(idx *index, in map[int64]*accounts.Account) map[int64]*accounts.Account {
+		for join, aa := range idx.byJoin {
 			if !compare(join) {
 				continue
 			}
@@ -292,9 +309,9 @@ func (d *Datastore) FilterJoined(compare CompareDatesFunc) FilterFunc {
 		return in
 	}
 
-	return func(in map[int64]*accounts.Account) map[int64]*accounts.Account {
+	return func(idx *index, in map[int64]*accounts.Account) map[int64]*accounts.Account {
 		if len(in) == 0 {
-			return getByJoin(in)
+			return getByJoin(idx, in)
 		}
 		return filterByJoin(in)
 	}
@@ -302,8 +319,8 @@ func (d *Datastore) FilterJoined(compare CompareDatesFunc) FilterFunc {
 
 // FilterBirth filters accounts with birth matching a function.
 func (d *Datastore) FilterBirth(compare CompareDatesFunc) FilterFunc {
-	getByBirth := func(in map[int64]*accounts.Account) map[int64]*accounts.Account {
-		for birth, aa := range d.byBirth {
+	getByBirth := func(idx *index, in map[int64]*accounts.Account) map[int64]*accounts.Account {
+		for birth, aa := range idx.byBirth {
 			if !compare(birth) {
 				continue
 			}
@@ -324,9 +341,9 @@ func (d *Datastore) FilterBirth(compare CompareDatesFunc) FilterFunc {
 		return in
 	}
 
-	return func(in map[int64]*accounts.Account) map[int64]*accounts.Account {
+	return func(idx *index, in map[int64]*accounts.Account) map[int64]*accounts.Account {
 		if len(in) == 0 {
-			return getByBirth(in)
+			return getByBirth(idx, in)
 		}
 		return filterByBirth(in)
 	}
@@ -334,8 +351,8 @@ func (d *Datastore) FilterBirth(compare CompareDatesFunc) FilterFunc {
 
 // FilterCity filters accounts with city matching a function.
 func (d *Datastore) FilterCity(compare CompareFunc) FilterFunc {
-	getByCity := func(in map[int64]*accounts.Account) map[int64]*accounts.Account {
-		for city, aa := range d.byCity {
+	getByCity := func(idx *index, in map[int64]*accounts.Account) map[int64]*accounts.Account {
+		for city, aa := range idx.byCity {
 			if !compare(city) {
 				continue
 			}
@@ -356,9 +373,9 @@ func (d *Datastore) FilterCity(compare CompareFunc) FilterFunc {
 		return in
 	}
 
-	return func(in map[int64]*accounts.Account) map[int64]*accounts.Account {
+	return func(idx *index, in map[int64]*accounts.Account) map[int64]*accounts.Account {
 		if len(in) == 0 {
-			return getByCity(in)
+			return getByCity(idx, in)
 		}
 		return filterByCity(in)
 	}
@@ -366,8 +383,8 @@ func (d *Datastore) FilterCity(compare CompareFunc) FilterFunc {
 
 // FilterCountry filters accounts with country matching a function.
 func (d *Datastore) FilterCountry(compare CompareFunc) FilterFunc {
-	getByCountry := func(in map[int64]*accounts.Account) map[int64]*accounts.Account {
-		for country, aa := range d.byCountry {
+	getByCountry := func(idx *index, in map[int64]*accounts.Account) map[int64]*accounts.Account {
+		for country, aa := range idx.byCountry {
 			if !compare(country) {
 				continue
 			}
@@ -388,9 +405,9 @@ func (d *Datastore) FilterCountry(compare CompareFunc) FilterFunc {
 		return in
 	}
 
-	return func(in map[int64]*accounts.Account) map[int64]*accounts.Account {
+	return func(idx *index, in map[int64]*accounts.Account) map[int64]*accounts.Account {
 		if len(in) == 0 {
-			return getByCountry(in)
+			return getByCountry(idx, in)
 		}
 		return filterByCountry(in)
 	}
@@ -398,12 +415,14 @@ func (d *Datastore) FilterCountry(compare CompareFunc) FilterFunc {
 
 // FilterPhone filters accounts with phone matching a function.
 func (d *Datastore) FilterPhone(compare CompareFunc) FilterFunc {
-	getByPhone := func(in map[int64]*accounts.Account) map[int64]*accounts.Account {
-		for phone, a := range d.byPhone {
+	getByPhone := func(idx *index, in map[int64]*accounts.Account) map[int64]*accounts.Account {
+		for phone, aa := range idx.byPhone {
 			if !compare(phone) {
 				continue
 			}
-			in[a.ID] = a
+			for _, a := range aa {
+				in[a.ID] = a
+			}
 		}
 		return in
 	}
@@ -418,9 +437,9 @@ func (d *Datastore) FilterPhone(compare CompareFunc) FilterFunc {
 		return in
 	}
 
-	return func(in map[int64]*accounts.Account) map[int64]*accounts.Account {
+	return func(idx *index, in map[int64]*accounts.Account) map[int64]*accounts.Account {
 		if len(in) == 0 {
-			return getByPhone(in)
+			return getByPhone(idx, in)
 		}
 		return filterByPhone(in)
 	}
@@ -428,8 +447,8 @@ func (d *Datastore) FilterPhone(compare CompareFunc) FilterFunc {
 
 // FilterSName filters accounts with sname matching a function.
 func (d *Datastore) FilterSName(compare CompareFunc) FilterFunc {
-	getBySName := func(in map[int64]*accounts.Account) map[int64]*accounts.Account {
-		for sname, aa := range d.bySName {
+	getBySName := func(idx *index, in map[int64]*accounts.Account) map[int64]*accounts.Account {
+		for sname, aa := range idx.bySName {
 			if !compare(sname) {
 				continue
 			}
@@ -450,9 +469,9 @@ func (d *Datastore) FilterSName(compare CompareFunc) FilterFunc {
 		return in
 	}
 
-	return func(in map[int64]*accounts.Account) map[int64]*accounts.Account {
+	return func(idx *index, in map[int64]*accounts.Account) map[int64]*accounts.Account {
 		if len(in) == 0 {
-			return getBySName(in)
+			return getBySName(idx, in)
 		}
 		return filterBySName(in)
 	}
@@ -460,8 +479,8 @@ func (d *Datastore) FilterSName(compare CompareFunc) FilterFunc {
 
 // FilterFName filters accounts with fname matching a function.
 func (d *Datastore) FilterFName(compare CompareFunc) FilterFunc {
-	getByFName := func(in map[int64]*accounts.Account) map[int64]*accounts.Account {
-		for fname, aa := range d.byFName {
+	getByFName := func(idx *index, in map[int64]*accounts.Account) map[int64]*accounts.Account {
+		for fname, aa := range idx.byFName {
 			if !compare(fname) {
 				continue
 			}
@@ -482,9 +501,9 @@ func (d *Datastore) FilterFName(compare CompareFunc) FilterFunc {
 		return in
 	}
 
-	return func(in map[int64]*accounts.Account) map[int64]*accounts.Account {
+	return func(idx *index, in map[int64]*accounts.Account) map[int64]*accounts.Account {
 		if len(in) == 0 {
-			return getByFName(in)
+			return getByFName(idx, in)
 		}
 		return filterByFName(in)
 	}
@@ -492,8 +511,8 @@ func (d *Datastore) FilterFName(compare CompareFunc) FilterFunc {
 
 // FilterStatus filters accounts with status matching a function.
 func (d *Datastore) FilterStatus(compare CompareFunc) FilterFunc {
-	getByStatus := func(in map[int64]*accounts.Account) map[int64]*accounts.Account {
-		for status, aa := range d.byStatus {
+	getByStatus := func(idx *index, in map[int64]*accounts.Account) map[int64]*accounts.Account {
+		for status, aa := range idx.byStatus {
 			if !compare(status) {
 				continue
 			}
@@ -514,9 +533,9 @@ func (d *Datastore) FilterStatus(compare CompareFunc) FilterFunc {
 		return in
 	}
 
-	return func(in map[int64]*accounts.Account) map[int64]*accounts.Account {
+	return func(idx *index, in map[int64]*accounts.Account) map[int64]*accounts.Account {
 		if len(in) == 0 {
-			return getByStatus(in)
+			return getByStatus(idx, in)
 		}
 		return filterByStatus(in)
 	}
@@ -524,8 +543,8 @@ func (d *Datastore) FilterStatus(compare CompareFunc) FilterFunc {
 
 // FilterEmail filters accounts with email matching a function.
 func (d *Datastore) FilterEmail(compare CompareFunc) FilterFunc {
-	getByEmail := func(in map[int64]*accounts.Account) map[int64]*accounts.Account {
-		for email, a := range d.byEmail {
+	getByEmail := func(idx *index, in map[int64]*accounts.Account) map[int64]*accounts.Account {
+		for email, a := range idx.byEmail {
 			if !compare(email) {
 				continue
 			}
@@ -544,9 +563,9 @@ func (d *Datastore) FilterEmail(compare CompareFunc) FilterFunc {
 		return in
 	}
 
-	return func(in map[int64]*accounts.Account) map[int64]*accounts.Account {
+	return func(idx *index, in map[int64]*accounts.Account) map[int64]*accounts.Account {
 		if len(in) == 0 {
-			return getByEmail(in)
+			return getByEmail(idx, in)
 		}
 		return filterByEmail(in)
 	}
@@ -554,8 +573,8 @@ func (d *Datastore) FilterEmail(compare CompareFunc) FilterFunc {
 
 // FilterSex filters accounts with given sex.
 func (d *Datastore) FilterSex(compare CompareFunc) FilterFunc {
-	getBySex := func(in map[int64]*accounts.Account) map[int64]*accounts.Account {
-		for sex, aa := range d.bySex {
+	getBySex := func(idx *index, in map[int64]*accounts.Account) map[int64]*accounts.Account {
+		for sex, aa := range idx.bySex {
 			if !compare(sex) {
 				continue
 			}
@@ -576,9 +595,9 @@ func (d *Datastore) FilterSex(compare CompareFunc) FilterFunc {
 		return in
 	}
 
-	return func(in map[int64]*accounts.Account) map[int64]*accounts.Account {
+	return func(idx *index, in map[int64]*accounts.Account) map[int64]*accounts.Account {
 		if len(in) == 0 {
-			return getBySex(in)
+			return getBySex(idx, in)
 		}
 		return filterBySex(in)
 	}
@@ -1,22 +1,18 @@
 package datastore
 
 import (
-	"bytes"
 	"sort"
 	"strings"
 
 	"github.com/ngalayko/highloadcup/app/accounts"
 )
 
-// GroupKeyFunc used to get value for a group key.
+// GroupKeyFunc used to get values for a group key, an empty value means null.
 type GroupKeyFunc func(*accounts.Account) (string, []string)
 
 // GroupSex returns sex value.
 func GroupSex() GroupKeyFunc {
 	return func(a *accounts.Account) (string, []string) {
-		if a.Sex == "" {
-			return "sex", nil
-		}
 		return "sex", []string{a.Sex}
 	}
 }
@@ -24,14 +20,12 @@ func GroupSex() GroupKeyFunc {
 // GroupStatus returns status value.
 func GroupStatus() GroupKeyFunc {
 	return func(a *accounts.Account) (string, []string) {
-		if a.Status == "" {
-			return "status", nil
-		}
 		return "status", []string{a.Status}
 	}
 }
 
-// GroupInterests returns status value.
+// GroupInterests returns interests values, an account is counted in a group
+// of every its interest.
 func GroupInterests() GroupKeyFunc {
 	return func(a *accounts.Account) (string, []string) {
 		return "interests", a.Interests
@@ -41,85 +35,108 @@ func GroupInterests() GroupKeyFunc {
 // GroupCountry returns country value.
 func GroupCountry() GroupKeyFunc {
 	return func(a *accounts.Account) (string, []string) {
-		if a.Country == "" {
-			return "country", nil
-		}
 		return "country", []string{a.Country}
 	}
 }
 
-// GroupCity returns country value.
+// GroupCity returns city value.
 func GroupCity() GroupKeyFunc {
 	return func(a *accounts.Account) (string, []string) {
-		if a.City == "" {
-			return "city", nil
-		}
 		return "city", []string{a.City}
 	}
 }
 
 // GroupAccounts returns account groups by given filters and keys.
 func (d *Datastore) GroupAccounts(keys []GroupKeyFunc, order bool, limit int, ff ...FilterFunc) ([]map[string]interface{}, error) {
-	filtered, err := d.FilterAccounts(-1, ff...)
+	return d.GroupAccountsPlan(nil, keys, order, limit, ff...)
+}
+
+// GroupAccountsPlan returns account groups by given filters and keys
+// recording execution details to a plan if it is not nil.
+func (d *Datastore) GroupAccountsPlan(plan *Plan, keys []GroupKeyFunc, order bool, limit int, ff ...FilterFunc) ([]map[string]interface{}, error) {
+	start := plan.start()
+	defer plan.finish(start)
+
+	filtered, err := d.FilterAccountsPlan(plan, -1, ff...)
 	if err != nil {
 		return nil, err
 	}
 
-	rawRes := make(map[string]int, len(keys))
+	groupStart := plan.start()
+
+	names := make([]string, len(keys))
+	groups := make(map[string]map[string]interface{})
 	for _, a := range filtered {
-		key := &bytes.Buffer{}
-		for _, group := range keys {
+		combinations := [][]string{{}}
+		for i, group := range keys {
 			name, values := group(a)
-			for _, value := range values {
-				if key.Len() != 0 {
-					key.WriteString(",")
+			names[i] = name
+
+			next := make([][]string, 0, len(combinations)*len(values))
+			for _, c := range combinations {
+				for _, value := range values {
+					next = append(next, append(c[:len(c):len(c)], value))
 				}
-				key.WriteString(name)
-				key.WriteString("=")
-				key.WriteString(value)
 			}
+			combinations = next
 		}
-		if key.Len() == 0 {
-			continue
-		}
-		rawRes[key.String()]++
-	}
 
-	var additionalSortField string
-	result := make([]map[string]interface{}, 0, len(rawRes))
-	for k, v := range rawRes {
-		fields := strings.Split(k, ",")
-		part := make(map[string]interface{}, len(fields))
-		for _, kv := range fields {
-			kvSlice := strings.Split(kv, "=")
-			part[kvSlice[0]] = kvSlice[1]
+		for _, values := range combinations {
+			key := strings.Join(values, "\x00")
+			if g, exists := groups[key]; exists {
+				g["count"] = g["count"].(int) + 1
+				continue
+			}
 
-			additionalSortField = kvSlice[0]
+			g := make(map[string]interface{}, len(values)+1)
+			for i, value := range values {
+				if value == "" {
+					continue
+				}
+				g[names[i]] = value
+			}
+			// a group of accounts without any key value has all keys null.
+			if len(g) == 0 {
+				for _, name := range names {
+					g[name] = nil
+				}
+			}
+			g["count"] = 1
+			groups[key] = g
 		}
-		part["count"] = v
-
-		result = append(result, part)
 	}
 
-	additionalSortFunc := func(i, j int) bool {
-		if order {
-			return result[i][additionalSortField].(string) > result[j][additionalSortField].(string)
-		}
-		return result[i][additionalSortField].(string) < result[j][additionalSortField].(string)
+	result := make([]map[string]interface{}, 0, len(groups))
+	for _, g := range groups {
+		result = append(result, g)
+	}
+	if plan != nil {
+		plan.Groups = len(result)
 	}
 
-	mainSortFunc := func(i, j int) bool {
-		if result[i]["count"].(int) == result[j]["count"].(int) {
-			return additionalSortFunc(i, j)
+	// groups are ordered by count, then by key values in the keys order.
+	less := func(i, j int) bool {
+		if result[i]["count"].(int) != result[j]["count"].(int) {
+			return result[i]["count"].(int) < result[j]["count"].(int)
+		}
+		for _, name := range names {
+			vi, _ := result[i][name].(string)
+			vj, _ := result[j][name].(string)
+			if vi != vj {
+				return vi < vj
+			}
 		}
+		return false
+	}
 
+	sort.Slice(result, func(i, j int) bool {
 		if order {
-			return result[i]["count"].(int) > result[j]["count"].(int)
+			return less(j, i)
 		}
-		return result[i]["count"].(int) < result[j]["count"].(int)
-	}
+		return less(i, j)
+	})
 
-	sort.Slice(result, mainSortFunc)
+	plan.group(len(filtered), groupStart)
 
 	if len(result) <= limit {
 		return result, nil
@@ -0,0 +1,111 @@
+package datastore
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/ngalayko/highloadcup/app/accounts"
+)
+
+// index holds secondary indexes built from a set of accounts.
+// Once built it is never modified, so it can be read without locks.
+type index struct {
+	ordered      []*accounts.Account
+	bySex        map[string][]*accounts.Account
+	byEmail      map[string]*accounts.Account
+	byStatus     map[string][]*accounts.Account
+	byFName      map[string][]*accounts.Account
+	bySName      map[string][]*accounts.Account
+	byPhone      map[string][]*accounts.Account
+	byCountry    map[string][]*accounts.Account
+	byCity       map[string][]*accounts.Account
+	byBirth      map[time.Time][]*accounts.Account
+	byJoin       map[time.Time][]*accounts.Account
+	byInterest   map[string][]*accounts.Account
+	likedBy      map[string][]*accounts.Account
+	premiumStart map[time.Time][]*accounts.Account
+	premiumEnd   map[time.Time][]*accounts.Account
+	premium      []*accounts.Account
+	noPremium    []*accounts.Account
+}
+
+func newIndex(aa []*accounts.Account) *index {
+	idx := &index{
+		ordered:      aa,
+		bySex:        map[string][]*accounts.Account{},
+		byEmail:      map[string]*accounts.Account{},
+		byStatus:     map[string][]*accounts.Account{},
+		byFName:      map[string][]*accounts.Account{},
+		bySName:      map[string][]*accounts.Account{},
+		byPhone:      map[string][]*accounts.Account{},
+		byCountry:    map[string][]*accounts.Account{},
+		byCity:       map[string][]*accounts.Account{},
+		byBirth:      map[time.Time][]*accounts.Account{},
+		byJoin:       map[time.Time][]*accounts.Account{},
+		byInterest:   map[string][]*accounts.Account{},
+		likedBy:      map[string][]*accounts.Account{},
+		premiumStart: map[time.Time][]*accounts.Account{},
+		premiumEnd:   map[time.Time][]*accounts.Account{},
+	}
+	for _, a := range aa {
+		idx.add(a)
+	}
+	sort.Slice(idx.ordered, func(i, j int) bool {
+		return idx.ordered[i].ID > idx.ordered[j].ID
+	})
+	return idx
+}
+
+func (idx *index) add(a *accounts.Account) {
+	idx.bySex[a.Sex] = append(idx.bySex[a.Sex], a)
+
+	idx.byEmail[a.Email] = a
+	idx.byStatus[a.Status] = append(idx.byStatus[a.Status], a)
+	idx.byFName[a.FName] = append(idx.byFName[a.FName], a)
+	idx.bySName[a.SName] = append(idx.bySName[a.SName], a)
+	idx.byPhone[a.Phone] = append(idx.byPhone[a.Phone], a)
+	idx.byCountry[a.Country] = append(idx.byCountry[a.Country], a)
+	idx.byCity[a.City] = append(idx.byCity[a.City], a)
+
+	birth := time.Unix(a.Birth, 0)
+	idx.byBirth[birth] = append(idx.byBirth[birth], a)
+
+	join := time.Unix(a.Joined, 0)
+	idx.byJoin[join] = append(idx.byJoin[join], a)
+
+	for _, i := range a.Interests {
+		idx.byInterest[i] = append(idx.byInterest[i], a)
+	}
+
+	for like := range a.LikesMap {
+		idx.likedBy[like] = append(idx.likedBy[like], a)
+	}
+
+	if a.Premium == nil {
+		idx.noPremium = append(idx.noPremium, a)
+		return
+	}
+
+	pStart := time.Unix(a.Premium.Start, 0)
+	idx.premiumStart[pStart] = append(idx.premiumStart[pStart], a)
+
+	pEnd := time.Unix(a.Premium.Finish, 0)
+	idx.premiumEnd[pEnd] = append(idx.premiumEnd[pEnd], a)
+
+	idx.premium = append(idx.premium, a)
+}
+
+// prepare fills account fields derived from its data.
+// It must be called before an account is shared with an index.
+func prepare(a *accounts.Account) {
+	a.InterestsMap = make(map[string]bool, len(a.Interests))
+	for _, i := range a.Interests {
+		a.InterestsMap[i] = true
+	}
+
+	a.LikesMap = make(map[string]bool, len(a.Likes))
+	for _, like := range a.Likes {
+		a.LikesMap[fmt.Sprint(like.ID)] = true
+	}
+}
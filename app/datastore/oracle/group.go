@@ -0,0 +1,111 @@
+package oracle
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/ngalayko/highloadcup/app/accounts"
+)
+
+// Group is a number of accounts with the same key values.
+type Group struct {
+	Values map[string]string
+	Count  int
+}
+
+// Response returns a group as it is served: keys with empty values are
+// omitted, unless all values are empty, then all of them are null.
+func (g *Group) Response() map[string]interface{} {
+	r := map[string]interface{}{
+		"count": g.Count,
+	}
+	for key, value := range g.Values {
+		if value != "" {
+			r[key] = value
+		}
+	}
+	if len(r) == 1 {
+		for key := range g.Values {
+			r[key] = nil
+		}
+	}
+	return r
+}
+
+// GroupBy groups accounts matching predicates by keys. Groups are ordered by
+// count, then by key values in keys order, descending if desc is true.
+func GroupBy(aa []*accounts.Account, keys []string, desc bool, limit int, pp ...Predicate) []*Group {
+	byKey := map[string]*Group{}
+	for _, a := range Filter(aa, -1, pp...) {
+		for _, values := range combinations(a, keys) {
+			parts := make([]string, 0, len(keys))
+			for _, key := range keys {
+				parts = append(parts, values[key])
+			}
+
+			id := strings.Join(parts, "\x00")
+			g, exists := byKey[id]
+			if !exists {
+				g = &Group{
+					Values: values,
+				}
+				byKey[id] = g
+			}
+			g.Count++
+		}
+	}
+
+	groups := make([]*Group, 0, len(byKey))
+	for _, g := range byKey {
+		groups = append(groups, g)
+	}
+
+	less := func(i, j int) bool {
+		if groups[i].Count != groups[j].Count {
+			return groups[i].Count < groups[j].Count
+		}
+		for _, key := range keys {
+			if groups[i].Values[key] != groups[j].Values[key] {
+				return groups[i].Values[key] < groups[j].Values[key]
+			}
+		}
+		return false
+	}
+	sort.Slice(groups, func(i, j int) bool {
+		if desc {
+			return less(j, i)
+		}
+		return less(i, j)
+	})
+
+	if limit >= 0 && len(groups) > limit {
+		groups = groups[:limit]
+	}
+	return groups
+}
+
+// combinations returns all key values combinations of an account,
+// an account has one combination for every interest.
+func combinations(a *accounts.Account, keys []string) []map[string]string {
+	combs := []map[string]string{{}}
+	for _, key := range keys {
+		values := []string{Field(a, key)}
+		if key == "interests" {
+			values = a.Interests
+		}
+
+		next := make([]map[string]string, 0, len(combs)*len(values))
+		for _, comb := range combs {
+			for _, v := range values {
+				c := make(map[string]string, len(comb)+1)
+				for k, cv := range comb {
+					c[k] = cv
+				}
+				c[key] = v
+				next = append(next, c)
+			}
+		}
+		combs = next
+	}
+	return combs
+}
@@ -0,0 +1,238 @@
+// Package oracle is a naive linear scan implementation of datastore queries.
+// It is slow but obviously correct, and is used as a reference in tests.
+package oracle
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ngalayko/highloadcup/app/accounts"
+)
+
+// Predicate returns true if an account matches a filter.
+type Predicate func(*accounts.Account) bool
+
+// Filter returns accounts matching all predicates ordered by id descending.
+// All matching accounts are returned if limit is negative.
+func Filter(aa []*accounts.Account, limit int, pp ...Predicate) []*accounts.Account {
+	res := []*accounts.Account{}
+	for _, a := range aa {
+		if match(a, pp) {
+			res = append(res, a)
+		}
+	}
+
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].ID > res[j].ID
+	})
+
+	if limit >= 0 && len(res) > limit {
+		res = res[:limit]
+	}
+	return res
+}
+
+func match(a *accounts.Account, pp []Predicate) bool {
+	for _, p := range pp {
+		if !p(a) {
+			return false
+		}
+	}
+	return true
+}
+
+// Field returns account field value by name.
+func Field(a *accounts.Account, name string) string {
+	switch name {
+	case "sex":
+		return a.Sex
+	case "email":
+		return a.Email
+	case "status":
+		return a.Status
+	case "fname":
+		return a.FName
+	case "sname":
+		return a.SName
+	case "phone":
+		return a.Phone
+	case "country":
+		return a.Country
+	case "city":
+		return a.City
+	default:
+		return ""
+	}
+}
+
+// Eq returns predicate for field equal to value.
+func Eq(field string, value string) Predicate {
+	return func(a *accounts.Account) bool {
+		return Field(a, field) == value
+	}
+}
+
+// Neq returns predicate for field not equal to value.
+func Neq(field string, value string) Predicate {
+	return func(a *accounts.Account) bool {
+		return Field(a, field) != value
+	}
+}
+
+// Any returns predicate for field equal to any of comma separated values.
+func Any(field string, values string) Predicate {
+	vv := strings.Split(values, ",")
+	return func(a *accounts.Account) bool {
+		for _, v := range vv {
+			if Field(a, field) == v {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// Null returns predicate for empty field if null is "1" or for non empty otherwise.
+func Null(field string, null string) Predicate {
+	return func(a *accounts.Account) bool {
+		return (Field(a, field) == "") == (null == "1")
+	}
+}
+
+// Starts returns predicate for field starting with prefix.
+func Starts(field string, prefix string) Predicate {
+	return func(a *accounts.Account) bool {
+		return strings.HasPrefix(Field(a, field), prefix)
+	}
+}
+
+// Lt returns predicate for field lexicographically less than value.
+func Lt(field string, value string) Predicate {
+	return func(a *accounts.Account) bool {
+		return Field(a, field) < value
+	}
+}
+
+// Gt returns predicate for field lexicographically greater than value.
+func Gt(field string, value string) Predicate {
+	return func(a *accounts.Account) bool {
+		return Field(a, field) > value
+	}
+}
+
+// EmailDomain returns predicate for email in domain.
+func EmailDomain(domain string) Predicate {
+	return func(a *accounts.Account) bool {
+		at := strings.LastIndex(a.Email, "@")
+		return at >= 0 && a.Email[at+1:] == domain
+	}
+}
+
+// PhoneCode returns predicate for phone with code in parentheses.
+func PhoneCode(code string) Predicate {
+	return func(a *accounts.Account) bool {
+		from := strings.Index(a.Phone, "(")
+		to := strings.Index(a.Phone, ")")
+		return from >= 0 && to > from && a.Phone[from+1:to] == code
+	}
+}
+
+// BirthLt returns predicate for birth before ts.
+func BirthLt(ts int64) Predicate {
+	return func(a *accounts.Account) bool {
+		return a.Birth < ts
+	}
+}
+
+// BirthGt returns predicate for birth after ts.
+func BirthGt(ts int64) Predicate {
+	return func(a *accounts.Account) bool {
+		return a.Birth > ts
+	}
+}
+
+// BirthYear returns predicate for birth in a year.
+func BirthYear(year int) Predicate {
+	return func(a *accounts.Account) bool {
+		return time.Unix(a.Birth, 0).UTC().Year() == year
+	}
+}
+
+// JoinedYear returns predicate for joined in a year.
+func JoinedYear(year int) Predicate {
+	return func(a *accounts.Account) bool {
+		return time.Unix(a.Joined, 0).UTC().Year() == year
+	}
+}
+
+// InterestsContains returns predicate for accounts with all of comma separated interests.
+func InterestsContains(interests string) Predicate {
+	ii := strings.Split(interests, ",")
+	return func(a *accounts.Account) bool {
+		for _, i := range ii {
+			if !contains(a.Interests, i) {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// InterestsAny returns predicate for accounts with any of comma separated interests.
+func InterestsAny(interests string) Predicate {
+	ii := strings.Split(interests, ",")
+	return func(a *accounts.Account) bool {
+		for _, i := range ii {
+			if contains(a.Interests, i) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// LikesContains returns predicate for accounts who liked all of comma separated ids.
+func LikesContains(likes string) Predicate {
+	ids := strings.Split(likes, ",")
+	return func(a *accounts.Account) bool {
+		for _, id := range ids {
+			liked := false
+			for _, like := range a.Likes {
+				if strconv.FormatInt(like.ID, 10) == id {
+					liked = true
+					break
+				}
+			}
+			if !liked {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// PremiumNow returns predicate for accounts with premium active at now.
+func PremiumNow(now int64) Predicate {
+	return func(a *accounts.Account) bool {
+		return a.Premium != nil && a.Premium.Start <= now && now <= a.Premium.Finish
+	}
+}
+
+// PremiumNull returns predicate for accounts without premium if null is "1"
+// or with premium otherwise.
+func PremiumNull(null string) Predicate {
+	return func(a *accounts.Account) bool {
+		return (a.Premium == nil) == (null == "1")
+	}
+}
+
+func contains(ss []string, s string) bool {
+	for _, v := range ss {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
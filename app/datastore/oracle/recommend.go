@@ -0,0 +1,181 @@
+package oracle
+
+import (
+	"math"
+	"sort"
+
+	"github.com/ngalayko/highloadcup/app/accounts"
+)
+
+// statusRanks orders statuses from the most to the least compatible.
+var statusRanks = map[string]int{
+	"свободны":   0,
+	"всё сложно": 1,
+	"заняты":     2,
+}
+
+// Index holds accounts by id and their likes for Recommend and Suggest,
+// it is built once for a dataset.
+type Index struct {
+	aa   []*accounts.Account
+	byID map[int64]*accounts.Account
+	// likes are average like timestamps by likee id by liker id.
+	likes map[int64]map[int64]float64
+}
+
+// NewIndex is an index constructor.
+func NewIndex(aa []*accounts.Account) *Index {
+	x := &Index{
+		aa:    aa,
+		byID:  make(map[int64]*accounts.Account, len(aa)),
+		likes: make(map[int64]map[int64]float64, len(aa)),
+	}
+	for _, a := range aa {
+		x.byID[a.ID] = a
+		x.likes[a.ID] = likeTimes(a)
+	}
+	return x
+}
+
+// Recommend returns accounts of opposite sex with at least one common interest
+// matching predicates, the most compatible first: with active premium at now,
+// by status, by number of common interests, by age difference, then by id.
+// It returns false if there is no account with id.
+func (x *Index) Recommend(id int64, now int64, limit int, pp ...Predicate) ([]*accounts.Account, bool) {
+	target, exists := x.byID[id]
+	if !exists {
+		return nil, false
+	}
+
+	common := map[int64]int{}
+	res := []*accounts.Account{}
+	for _, a := range Filter(x.aa, -1, pp...) {
+		if a.Sex == target.Sex {
+			continue
+		}
+		for _, i := range a.Interests {
+			if contains(target.Interests, i) {
+				common[a.ID]++
+			}
+		}
+		if common[a.ID] == 0 {
+			continue
+		}
+		res = append(res, a)
+	}
+
+	premium := PremiumNow(now)
+	ageDiff := func(a *accounts.Account) int64 {
+		if a.Birth > target.Birth {
+			return a.Birth - target.Birth
+		}
+		return target.Birth - a.Birth
+	}
+
+	sort.Slice(res, func(i, j int) bool {
+		if premium(res[i]) != premium(res[j]) {
+			return premium(res[i])
+		}
+		if statusRanks[res[i].Status] != statusRanks[res[j].Status] {
+			return statusRanks[res[i].Status] < statusRanks[res[j].Status]
+		}
+		if common[res[i].ID] != common[res[j].ID] {
+			return common[res[i].ID] > common[res[j].ID]
+		}
+		if ageDiff(res[i]) != ageDiff(res[j]) {
+			return ageDiff(res[i]) < ageDiff(res[j])
+		}
+		return res[i].ID < res[j].ID
+	})
+
+	if limit >= 0 && len(res) > limit {
+		res = res[:limit]
+	}
+	return res, true
+}
+
+// likeTimes returns average like timestamps by likee id.
+func likeTimes(a *accounts.Account) map[int64]float64 {
+	sums := map[int64]float64{}
+	counts := map[int64]float64{}
+	for _, like := range a.Likes {
+		sums[like.ID] += float64(like.Timestamp)
+		counts[like.ID]++
+	}
+	for id := range sums {
+		sums[id] /= counts[id]
+	}
+	return sums
+}
+
+// Suggest returns accounts liked by accounts of the same sex whose likes are
+// similar to ones of an account with id. Similar accounts matching predicates
+// are taken from the most similar, their likes not liked by the account are
+// added from the largest id.
+// Similarity is a sum of |ts1-ts2| for common likes, where ts are average
+// timestamps of likes of the same account. Reference answers rank accounts
+// by the sum itself, not by 1/|ts1-ts2| the task describes.
+// It returns false if there is no account with id.
+func (x *Index) Suggest(id int64, limit int, pp ...Predicate) ([]*accounts.Account, bool) {
+	target, exists := x.byID[id]
+	if !exists {
+		return nil, false
+	}
+
+	liked := x.likes[id]
+	similarity := map[int64]float64{}
+	similar := []*accounts.Account{}
+	common := map[int64]bool{}
+	for _, a := range Filter(x.aa, -1, pp...) {
+		if a.ID == target.ID || a.Sex != target.Sex {
+			continue
+		}
+		for likee, ts := range x.likes[a.ID] {
+			targetTs, exists := liked[likee]
+			if !exists {
+				continue
+			}
+			common[a.ID] = true
+			similarity[a.ID] += math.Abs(targetTs - ts)
+		}
+		if !common[a.ID] {
+			continue
+		}
+		similar = append(similar, a)
+	}
+
+	sort.Slice(similar, func(i, j int) bool {
+		if similarity[similar[i].ID] != similarity[similar[j].ID] {
+			return similarity[similar[i].ID] > similarity[similar[j].ID]
+		}
+		return similar[i].ID < similar[j].ID
+	})
+
+	added := map[int64]bool{}
+	res := []*accounts.Account{}
+	for _, a := range similar {
+		ids := []int64{}
+		for likee := range x.likes[a.ID] {
+			if _, exists := liked[likee]; exists || added[likee] {
+				continue
+			}
+			ids = append(ids, likee)
+		}
+		sort.Slice(ids, func(i, j int) bool {
+			return ids[i] > ids[j]
+		})
+
+		for _, id := range ids {
+			if limit >= 0 && len(res) >= limit {
+				return res, true
+			}
+			s, exists := x.byID[id]
+			if !exists {
+				continue
+			}
+			added[id] = true
+			res = append(res, s)
+		}
+	}
+	return res, true
+}
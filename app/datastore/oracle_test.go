@@ -0,0 +1,516 @@
+package datastore_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"os"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ngalayko/highloadcup/app/accounts"
+	"github.com/ngalayko/highloadcup/app/apptest"
+	"github.com/ngalayko/highloadcup/app/datastore"
+	"github.com/ngalayko/highloadcup/app/datastore/oracle"
+	"github.com/ngalayko/highloadcup/app/generator"
+	"github.com/ngalayko/highloadcup/app/importer/zip"
+	"github.com/ngalayko/highloadcup/app/logger"
+)
+
+const (
+	testAccounts = 500
+	testQueries  = 2000
+)
+
+// testNow is a dataset current time, premiums are generated around it.
+var testNow = time.Date(2018, 6, 1, 0, 0, 0, 0, time.UTC).Unix()
+
+// memImporter imports accounts from memory.
+type memImporter struct {
+	data    []byte
+	modTime time.Time
+}
+
+func (i *memImporter) Read() ([]io.Reader, error) {
+	return []io.Reader{bytes.NewReader(i.data)}, nil
+}
+
+func (i *memImporter) ModTime() (time.Time, error) {
+	return i.modTime, nil
+}
+
+func (i *memImporter) Size() (int64, error) {
+	return int64(len(i.data)), nil
+}
+
+// generate returns n accounts of the synthetic dataset.
+func generate(n int) []*accounts.Account {
+	return generator.New(&generator.Config{Accounts: n, Seed: 1, Now: testNow}).Generate()
+}
+
+// pick returns a non empty field value of a random account.
+func pick(r *rand.Rand, aa []*accounts.Account, field func(a *accounts.Account) string) string {
+	for {
+		if v := field(aa[r.Intn(len(aa))]); v != "" {
+			return v
+		}
+	}
+}
+
+func fnameOf(a *accounts.Account) string {
+	return a.FName
+}
+
+func countryOf(a *accounts.Account) string {
+	return a.Country
+}
+
+func cityOf(a *accounts.Account) string {
+	return a.City
+}
+
+func codeOf(a *accounts.Account) string {
+	if a.Phone == "" {
+		return ""
+	}
+	return a.Phone[strings.Index(a.Phone, "(")+1 : strings.Index(a.Phone, ")")]
+}
+
+func interestOf(r *rand.Rand) func(a *accounts.Account) string {
+	return func(a *accounts.Account) string {
+		if len(a.Interests) == 0 {
+			return ""
+		}
+		return a.Interests[r.Intn(len(a.Interests))]
+	}
+}
+
+func newDatastore(t testing.TB, aa []*accounts.Account, opts ...datastore.Option) *datastore.Datastore {
+	data, err := json.Marshal(map[string]interface{}{
+		"accounts": aa,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	opts = append([]datastore.Option{datastore.WithNow(testNow)}, opts...)
+	d, err := datastore.New(logger.New(), &memImporter{data: data}, opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return d
+}
+
+type filterCase func(d *datastore.Datastore, r *rand.Rand, aa []*accounts.Account, a *accounts.Account) (string, datastore.FilterFunc, oracle.Predicate)
+
+func mustCompareDates(f datastore.CompareDatesFunc, err error) datastore.CompareDatesFunc {
+	if err != nil {
+		panic(err)
+	}
+	return f
+}
+
+func mustFilter(f datastore.FilterFunc, err error) datastore.FilterFunc {
+	if err != nil {
+		panic(err)
+	}
+	return f
+}
+
+func null(r *rand.Rand) string {
+	return strconv.Itoa(r.Intn(2))
+}
+
+// filterCases mirror every filter predicate, values are taken from
+// a random account, so that filters mostly match something.
+var filterCases = []filterCase{
+	func(d *datastore.Datastore, r *rand.Rand, aa []*accounts.Account, a *accounts.Account) (string, datastore.FilterFunc, oracle.Predicate) {
+		return "sex_eq=" + a.Sex, d.FilterSex(datastore.Equal(a.Sex)), oracle.Eq("sex", a.Sex)
+	},
+	func(d *datastore.Datastore, r *rand.Rand, aa []*accounts.Account, a *accounts.Account) (string, datastore.FilterFunc, oracle.Predicate) {
+		domain := a.Email[strings.Index(a.Email, "@")+1:]
+		return "email_domain=" + domain, d.FilterEmail(datastore.Domain(domain)), oracle.EmailDomain(domain)
+	},
+	func(d *datastore.Datastore, r *rand.Rand, aa []*accounts.Account, a *accounts.Account) (string, datastore.FilterFunc, oracle.Predicate) {
+		return "email_lt=" + a.Email, d.FilterEmail(datastore.Lt(a.Email)), oracle.Lt("email", a.Email)
+	},
+	func(d *datastore.Datastore, r *rand.Rand, aa []*accounts.Account, a *accounts.Account) (string, datastore.FilterFunc, oracle.Predicate) {
+		return "email_gt=" + a.Email, d.FilterEmail(datastore.Gt(a.Email)), oracle.Gt("email", a.Email)
+	},
+	func(d *datastore.Datastore, r *rand.Rand, aa []*accounts.Account, a *accounts.Account) (string, datastore.FilterFunc, oracle.Predicate) {
+		return "status_eq=" + a.Status, d.FilterStatus(datastore.Equal(a.Status)), oracle.Eq("status", a.Status)
+	},
+	func(d *datastore.Datastore, r *rand.Rand, aa []*accounts.Account, a *accounts.Account) (string, datastore.FilterFunc, oracle.Predicate) {
+		return "status_neq=" + a.Status, d.FilterStatus(datastore.NotEqual(a.Status)), oracle.Neq("status", a.Status)
+	},
+	func(d *datastore.Datastore, r *rand.Rand, aa []*accounts.Account, a *accounts.Account) (string, datastore.FilterFunc, oracle.Predicate) {
+		return "fname_eq=" + a.FName, d.FilterFName(datastore.Equal(a.FName)), oracle.Eq("fname", a.FName)
+	},
+	func(d *datastore.Datastore, r *rand.Rand, aa []*accounts.Account, a *accounts.Account) (string, datastore.FilterFunc, oracle.Predicate) {
+		names := pick(r, aa, fnameOf) + "," + pick(r, aa, fnameOf)
+		return "fname_any=" + names, d.FilterFName(datastore.Any(names)), oracle.Any("fname", names)
+	},
+	func(d *datastore.Datastore, r *rand.Rand, aa []*accounts.Account, a *accounts.Account) (string, datastore.FilterFunc, oracle.Predicate) {
+		n := null(r)
+		return "fname_null=" + n, d.FilterFName(datastore.Null(n)), oracle.Null("fname", n)
+	},
+	func(d *datastore.Datastore, r *rand.Rand, aa []*accounts.Account, a *accounts.Account) (string, datastore.FilterFunc, oracle.Predicate) {
+		return "sname_eq=" + a.SName, d.FilterSName(datastore.Equal(a.SName)), oracle.Eq("sname", a.SName)
+	},
+	func(d *datastore.Datastore, r *rand.Rand, aa []*accounts.Account, a *accounts.Account) (string, datastore.FilterFunc, oracle.Predicate) {
+		prefix := a.SName
+		if len(prefix) > 4 {
+			prefix = prefix[:4]
+		}
+		return "sname_starts=" + prefix, d.FilterSName(datastore.Starts(prefix)), oracle.Starts("sname", prefix)
+	},
+	func(d *datastore.Datastore, r *rand.Rand, aa []*accounts.Account, a *accounts.Account) (string, datastore.FilterFunc, oracle.Predicate) {
+		n := null(r)
+		return "sname_null=" + n, d.FilterSName(datastore.Null(n)), oracle.Null("sname", n)
+	},
+	func(d *datastore.Datastore, r *rand.Rand, aa []*accounts.Account, a *accounts.Account) (string, datastore.FilterFunc, oracle.Predicate) {
+		code := pick(r, aa, codeOf)
+		return "phone_code=" + code, d.FilterPhone(datastore.Code(code)), oracle.PhoneCode(code)
+	},
+	func(d *datastore.Datastore, r *rand.Rand, aa []*accounts.Account, a *accounts.Account) (string, datastore.FilterFunc, oracle.Predicate) {
+		n := null(r)
+		return "phone_null=" + n, d.FilterPhone(datastore.Null(n)), oracle.Null("phone", n)
+	},
+	func(d *datastore.Datastore, r *rand.Rand, aa []*accounts.Account, a *accounts.Account) (string, datastore.FilterFunc, oracle.Predicate) {
+		return "country_eq=" + a.Country, d.FilterCountry(datastore.Equal(a.Country)), oracle.Eq("country", a.Country)
+	},
+	func(d *datastore.Datastore, r *rand.Rand, aa []*accounts.Account, a *accounts.Account) (string, datastore.FilterFunc, oracle.Predicate) {
+		n := null(r)
+		return "country_null=" + n, d.FilterCountry(datastore.Null(n)), oracle.Null("country", n)
+	},
+	func(d *datastore.Datastore, r *rand.Rand, aa []*accounts.Account, a *accounts.Account) (string, datastore.FilterFunc, oracle.Predicate) {
+		return "city_eq=" + a.City, d.FilterCity(datastore.Equal(a.City)), oracle.Eq("city", a.City)
+	},
+	func(d *datastore.Datastore, r *rand.Rand, aa []*accounts.Account, a *accounts.Account) (string, datastore.FilterFunc, oracle.Predicate) {
+		cities := pick(r, aa, cityOf) + "," + pick(r, aa, cityOf)
+		return "city_any=" + cities, d.FilterCity(datastore.Any(cities)), oracle.Any("city", cities)
+	},
+	func(d *datastore.Datastore, r *rand.Rand, aa []*accounts.Account, a *accounts.Account) (string, datastore.FilterFunc, oracle.Predicate) {
+		n := null(r)
+		return "city_null=" + n, d.FilterCity(datastore.Null(n)), oracle.Null("city", n)
+	},
+	func(d *datastore.Datastore, r *rand.Rand, aa []*accounts.Account, a *accounts.Account) (string, datastore.FilterFunc, oracle.Predicate) {
+		ts := strconv.FormatInt(a.Birth, 10)
+		return "birth_lt=" + ts, d.FilterBirth(mustCompareDates(datastore.Before(ts))), oracle.BirthLt(a.Birth)
+	},
+	func(d *datastore.Datastore, r *rand.Rand, aa []*accounts.Account, a *accounts.Account) (string, datastore.FilterFunc, oracle.Predicate) {
+		ts := strconv.FormatInt(a.Birth, 10)
+		return "birth_gt=" + ts, d.FilterBirth(mustCompareDates(datastore.After(ts))), oracle.BirthGt(a.Birth)
+	},
+	func(d *datastore.Datastore, r *rand.Rand, aa []*accounts.Account, a *accounts.Account) (string, datastore.FilterFunc, oracle.Predicate) {
+		year := time.Unix(a.Birth, 0).UTC().Year()
+		ys := strconv.Itoa(year)
+		return "birth_year=" + ys, d.FilterBirth(mustCompareDates(datastore.Year(ys))), oracle.BirthYear(year)
+	},
+	func(d *datastore.Datastore, r *rand.Rand, aa []*accounts.Account, a *accounts.Account) (string, datastore.FilterFunc, oracle.Predicate) {
+		year := time.Unix(a.Joined, 0).UTC().Year()
+		ys := strconv.Itoa(year)
+		return "joined=" + ys, d.FilterJoined(mustCompareDates(datastore.Year(ys))), oracle.JoinedYear(year)
+	},
+	func(d *datastore.Datastore, r *rand.Rand, aa []*accounts.Account, a *accounts.Account) (string, datastore.FilterFunc, oracle.Predicate) {
+		interests := pick(r, aa, interestOf(r)) + "," + pick(r, aa, interestOf(r))
+		return "interests_contains=" + interests, d.FilterInterestsContains([]byte(interests)), oracle.InterestsContains(interests)
+	},
+	func(d *datastore.Datastore, r *rand.Rand, aa []*accounts.Account, a *accounts.Account) (string, datastore.FilterFunc, oracle.Predicate) {
+		interests := pick(r, aa, interestOf(r)) + "," + pick(r, aa, interestOf(r))
+		return "interests_any=" + interests, d.FilterInterestsAny([]byte(interests)), oracle.InterestsAny(interests)
+	},
+	func(d *datastore.Datastore, r *rand.Rand, aa []*accounts.Account, a *accounts.Account) (string, datastore.FilterFunc, oracle.Predicate) {
+		if len(a.Likes) == 0 {
+			return "likes_contains=1", d.FilterLikesContains([]byte("1")), oracle.LikesContains("1")
+		}
+		likes := []string{}
+		for _, like := range a.Likes[:1+r.Intn(len(a.Likes))] {
+			likes = append(likes, strconv.FormatInt(like.ID, 10))
+		}
+		ll := strings.Join(likes, ",")
+		return "likes_contains=" + ll, d.FilterLikesContains([]byte(ll)), oracle.LikesContains(ll)
+	},
+	func(d *datastore.Datastore, r *rand.Rand, aa []*accounts.Account, a *accounts.Account) (string, datastore.FilterFunc, oracle.Predicate) {
+		return "premium_now=1", mustFilter(d.FilterPremiumNow("1")), oracle.PremiumNow(testNow)
+	},
+	func(d *datastore.Datastore, r *rand.Rand, aa []*accounts.Account, a *accounts.Account) (string, datastore.FilterFunc, oracle.Predicate) {
+		n := null(r)
+		return "premium_null=" + n, d.FilterPremiumNull(n), oracle.PremiumNull(n)
+	},
+}
+
+// randomFilters returns from one to three random filters and their description.
+func randomFilters(d *datastore.Datastore, r *rand.Rand, aa []*accounts.Account) (string, []datastore.FilterFunc, []oracle.Predicate) {
+	query := []string{}
+	ff := []datastore.FilterFunc{}
+	pp := []oracle.Predicate{}
+	for i := 1 + r.Intn(3); i > 0; i-- {
+		q, f, p := filterCases[r.Intn(len(filterCases))](d, r, aa, aa[r.Intn(len(aa))])
+		query = append(query, q)
+		ff = append(ff, f)
+		pp = append(pp, p)
+	}
+	return strings.Join(query, "&"), ff, pp
+}
+
+func ids(aa []*accounts.Account) []int64 {
+	res := make([]int64, 0, len(aa))
+	for _, a := range aa {
+		res = append(res, a.ID)
+	}
+	sort.Slice(res, func(i, j int) bool {
+		return res[i] > res[j]
+	})
+	return res
+}
+
+func TestFilterMatchesOracle(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	aa := generate(testAccounts)
+	d := newDatastore(t, aa)
+
+	for i := 0; i < testQueries; i++ {
+		query, ff, pp := randomFilters(d, r, aa)
+
+		filtered, err := d.FilterAccounts(-1, ff...)
+		if err != nil {
+			t.Fatalf("%s: %s", query, err)
+		}
+		got := make([]*accounts.Account, 0, len(filtered))
+		for _, a := range filtered {
+			got = append(got, a)
+		}
+
+		expected := oracle.Filter(aa, -1, pp...)
+		if !reflect.DeepEqual(ids(got), ids(expected)) {
+			t.Errorf("%s: expected %v, got %v", query, ids(expected), ids(got))
+		}
+	}
+}
+
+func TestFilterLimit(t *testing.T) {
+	aa := generate(testAccounts)
+	d := newDatastore(t, aa)
+
+	for _, limit := range []int{0, 1, testAccounts, testAccounts + 1} {
+		filtered, err := d.FilterAccounts(limit)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := make([]*accounts.Account, 0, len(filtered))
+		for _, a := range filtered {
+			got = append(got, a)
+		}
+
+		expected := oracle.Filter(aa, limit)
+		if !reflect.DeepEqual(ids(got), ids(expected)) {
+			t.Errorf("limit=%d: expected %v, got %v", limit, ids(expected), ids(got))
+		}
+	}
+}
+
+var groupKeys = map[string]datastore.GroupKeyFunc{
+	"sex":       datastore.GroupSex(),
+	"status":    datastore.GroupStatus(),
+	"interests": datastore.GroupInterests(),
+	"country":   datastore.GroupCountry(),
+	"city":      datastore.GroupCity(),
+}
+
+func TestGroupMatchesOracle(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	aa := generate(testAccounts)
+	d := newDatastore(t, aa)
+
+	names := []string{"sex", "status", "interests", "country", "city"}
+	for i := 0; i < testQueries; i++ {
+		r.Shuffle(len(names), func(i, j int) {
+			names[i], names[j] = names[j], names[i]
+		})
+		keys := names[:1+r.Intn(2)]
+
+		query := ""
+		ff := []datastore.FilterFunc{}
+		pp := []oracle.Predicate{}
+		if r.Intn(2) == 0 {
+			query, ff, pp = randomFilters(d, r, aa)
+		}
+		desc := r.Intn(2) == 0
+		limit := 1 + r.Intn(50)
+		query = fmt.Sprintf("keys=%s&order=%t&limit=%d&%s", strings.Join(keys, ","), desc, limit, query)
+
+		kk := make([]datastore.GroupKeyFunc, 0, len(keys))
+		for _, key := range keys {
+			kk = append(kk, groupKeys[key])
+		}
+		got, err := d.GroupAccounts(kk, desc, limit, ff...)
+		if err != nil {
+			t.Fatalf("%s: %s", query, err)
+		}
+
+		expected := []map[string]interface{}{}
+		for _, g := range oracle.GroupBy(aa, keys, desc, limit, pp...) {
+			expected = append(expected, g.Response())
+		}
+
+		if len(got) == 0 && len(expected) == 0 {
+			continue
+		}
+		if !reflect.DeepEqual(got, expected) {
+			t.Errorf("%s: expected %v, got %v", query, expected, got)
+		}
+	}
+}
+
+const (
+	testDataPath    = "../../test_data/data/data.zip"
+	testAnswersPath = "../../test_data/answers/phase_1_get.answ"
+)
+
+// TestSimilarOracleAnswers checks recommend and suggest oracles against
+// reference answers, so that generated answers are not derived from the app.
+func TestSimilarOracleAnswers(t *testing.T) {
+	if _, err := os.Stat(testDataPath); err != nil {
+		t.Skipf("no test data: %s", err)
+	}
+
+	d, err := datastore.New(logger.New(logger.WithLevel(logger.ErrorLevel)), zip.New(testDataPath))
+	if err != nil {
+		t.Fatal(err)
+	}
+	options, err := zip.New(testDataPath).Options()
+	if err != nil {
+		t.Fatal(err)
+	}
+	now, err := strconv.ParseInt(strings.TrimSpace(strings.SplitN(string(options), "\n", 2)[0]), 10, 64)
+	if err != nil {
+		t.Fatal(err)
+	}
+	index := oracle.NewIndex(d.Accounts())
+
+	aa, err := apptest.ParseAnswers(testAnswersPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	checked := 0
+	for _, a := range aa {
+		u, err := url.Parse(a.URI)
+		if err != nil {
+			t.Fatal(err)
+		}
+		parts := strings.Split(strings.Trim(u.Path, "/"), "/")
+		if len(parts) != 3 || a.StatusCode == http.StatusBadRequest {
+			continue
+		}
+		id, err := strconv.ParseInt(parts[1], 10, 64)
+		if err != nil {
+			continue
+		}
+		limit, err := strconv.Atoi(u.Query().Get("limit"))
+		if err != nil {
+			continue
+		}
+		pp := []oracle.Predicate{}
+		for _, key := range []string{"country", "city"} {
+			if v := u.Query().Get(key); v != "" {
+				pp = append(pp, oracle.Eq(key, v))
+			}
+		}
+
+		var res []*accounts.Account
+		var exists bool
+		switch parts[2] {
+		case "recommend":
+			res, exists = index.Recommend(id, now, limit, pp...)
+		case "suggest":
+			res, exists = index.Suggest(id, limit, pp...)
+		default:
+			continue
+		}
+		checked++
+
+		if a.StatusCode == http.StatusNotFound {
+			if exists {
+				t.Errorf("%s: expected unknown account", a.URI)
+			}
+			continue
+		}
+		expected := []int64{}
+		for _, account := range a.Response.(map[string]interface{})["accounts"].([]interface{}) {
+			id, _ := account.(map[string]interface{})["id"].(json.Number).Int64()
+			expected = append(expected, id)
+		}
+		got := []int64{}
+		for _, account := range res {
+			got = append(got, account.ID)
+		}
+		if len(got) == 0 && len(expected) == 0 {
+			continue
+		}
+		if !reflect.DeepEqual(got, expected) {
+			t.Errorf("%s: expected %v, got %v", a.URI, expected, got)
+		}
+	}
+	if checked == 0 {
+		t.Fatal("no recommend or suggest answers checked")
+	}
+}
+
+func TestSimilarOracleProperties(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	aa := generate(testAccounts)
+	index := oracle.NewIndex(aa)
+
+	for i := 0; i < testQueries; i++ {
+		target := aa[r.Intn(len(aa))]
+		limit := 1 + r.Intn(20)
+		query := fmt.Sprintf("id=%d&limit=%d", target.ID, limit)
+		pp := []oracle.Predicate{}
+		if r.Intn(4) != 0 {
+			city := pick(r, aa, cityOf)
+			query += "&city=" + city
+			pp = append(pp, oracle.Eq("city", city))
+		}
+
+		recommended, exists := index.Recommend(target.ID, testNow, limit, pp...)
+		if !exists || len(recommended) > limit {
+			t.Fatalf("recommend %s: unexpected %d accounts", query, len(recommended))
+		}
+		interests := oracle.InterestsAny(strings.Join(target.Interests, ","))
+		for _, a := range recommended {
+			if a.Sex == target.Sex || !interests(a) || len(pp) != 0 && !pp[0](a) {
+				t.Errorf("recommend %s: unexpected account %d", query, a.ID)
+			}
+		}
+
+		suggested, exists := index.Suggest(target.ID, limit, pp...)
+		if !exists || len(suggested) > limit {
+			t.Fatalf("suggest %s: unexpected %d accounts", query, len(suggested))
+		}
+		seen := map[int64]bool{}
+		for _, a := range suggested {
+			if seen[a.ID] || oracle.LikesContains(strconv.FormatInt(a.ID, 10))(target) {
+				t.Errorf("suggest %s: unexpected account %d", query, a.ID)
+			}
+			seen[a.ID] = true
+		}
+	}
+
+	unknown := aa[len(aa)-1].ID + 1
+	if _, exists := index.Recommend(unknown, testNow, 1); exists {
+		t.Error("expected unknown account not to be recommended for")
+	}
+	if _, exists := index.Suggest(unknown, 1); exists {
+		t.Error("expected unknown account not to be suggested for")
+	}
+}
@@ -0,0 +1,138 @@
+package datastore
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// seedIndexes are indexes filters take candidates from when they are
+// applied first, by field, parameter or parameter with a value.
+var seedIndexes = map[string]string{
+	"sex":       "bySex",
+	"email":     "byEmail",
+	"status":    "byStatus",
+	"fname":     "byFName",
+	"sname":     "bySName",
+	"phone":     "byPhone",
+	"country":   "byCountry",
+	"city":      "byCity",
+	"birth":     "byBirth",
+	"joined":    "byJoin",
+	"interests": "byInterest",
+	"likes":     "likedBy",
+	// premium_now is seeded from premiumStart, premium_null from premium
+	// or noPremium.
+	"premium_now":    "premiumStart",
+	"premium_null=0": "premium",
+	"premium_null=1": "noPremium",
+}
+
+// Plan records how a query was executed.
+type Plan struct {
+	// Predicates are names of filters in the order they are applied,
+	// e.g. city_eq=Москва.
+	Predicates []string
+	// Seed is an index candidates are taken from.
+	Seed  string
+	Steps []*PlanStep
+	// Groups is a number of groups before limit for group queries.
+	Groups   int
+	Duration time.Duration
+}
+
+// PlanStep is a filter applied by a query.
+type PlanStep struct {
+	Predicate string
+	// Candidates is a number of accounts left after the step.
+	Candidates int
+	// Estimated is a number of candidates expected by Estimate,
+	// -1 if there are no statistics for the predicate.
+	Estimated int
+	Duration  time.Duration
+}
+
+// NewPlan returns a plan for a query with filters named by predicates.
+func NewPlan(predicates ...string) *Plan {
+	p := &Plan{
+		Predicates: predicates,
+		Seed:       "ordered",
+	}
+	if len(predicates) > 0 {
+		p.Seed = seedIndex(predicates[0])
+	}
+	return p
+}
+
+// seedIndex returns an index a filter named by a predicate takes
+// candidates from.
+func seedIndex(predicate string) string {
+	if index, exists := seedIndexes[predicate]; exists {
+		return index
+	}
+	key := predicate
+	if i := strings.IndexByte(key, '='); i >= 0 {
+		key = key[:i]
+	}
+	if index, exists := seedIndexes[key]; exists {
+		return index
+	}
+	if i := strings.IndexByte(key, '_'); i >= 0 {
+		key = key[:i]
+	}
+	return seedIndexes[key]
+}
+
+// start returns the current time if the plan is recorded.
+func (p *Plan) start() time.Time {
+	if p == nil {
+		return time.Time{}
+	}
+	return time.Now()
+}
+
+// step records the i-th filter leaving candidates accounts.
+func (p *Plan) step(i int, candidates int, start time.Time) {
+	if p == nil {
+		return
+	}
+	predicate := fmt.Sprintf("filter#%d", i)
+	if i < len(p.Predicates) {
+		predicate = p.Predicates[i]
+	}
+	p.Steps = append(p.Steps, &PlanStep{
+		Predicate:  predicate,
+		Candidates: candidates,
+		Duration:   time.Since(start),
+	})
+}
+
+// group records grouping of accounts.
+func (p *Plan) group(accounts int, start time.Time) {
+	if p == nil {
+		return
+	}
+	p.Steps = append(p.Steps, &PlanStep{
+		Predicate:  "group",
+		Candidates: accounts,
+		Duration:   time.Since(start),
+	})
+}
+
+// finish records total query time.
+func (p *Plan) finish(start time.Time) {
+	if p == nil {
+		return
+	}
+	p.Duration = time.Since(start)
+}
+
+// String returns steps formatted as `predicate:candidates:duration`
+// separated by arrows.
+func (p *Plan) String() string {
+	steps := make([]string, 0, len(p.Steps))
+	for _, s := range p.Steps {
+		steps = append(steps, fmt.Sprintf("%s:%d:%s", s.Predicate, s.Candidates, s.Duration))
+	}
+	return strings.Join(steps, " -> ")
+}
@@ -0,0 +1,134 @@
+package datastore_test
+
+import (
+	"math/rand"
+	"reflect"
+	"testing"
+
+	"github.com/ngalayko/highloadcup/app/datastore"
+)
+
+func TestFilterPlan(t *testing.T) {
+	d := newDatastore(t, generate(testAccounts))
+
+	ff := []datastore.FilterFunc{
+		d.FilterSex(datastore.Equal("m")),
+		d.FilterStatus(datastore.NotEqual("заняты")),
+		d.FilterCity(datastore.Null("0")),
+	}
+	expected, err := d.FilterAccounts(-1, ff...)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	plan := datastore.NewPlan("sex_eq=m", "status_neq=заняты", "city_null=0")
+	aa, err := d.FilterAccountsPlan(plan, -1, ff...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(aa, expected) {
+		t.Fatal("plan changed filter results")
+	}
+
+	if plan.Seed != "bySex" {
+		t.Fatalf("expected bySex seed, got %s", plan.Seed)
+	}
+	if len(plan.Steps) != len(ff) {
+		t.Fatalf("expected %d steps, got %d", len(ff), len(plan.Steps))
+	}
+	for i, s := range plan.Steps {
+		if s.Predicate != plan.Predicates[i] {
+			t.Fatalf("expected step %s, got %s", plan.Predicates[i], s.Predicate)
+		}
+		if i > 0 && s.Candidates > plan.Steps[i-1].Candidates {
+			t.Fatalf("candidates grew after %s: %d > %d", s.Predicate, s.Candidates, plan.Steps[i-1].Candidates)
+		}
+	}
+	if last := plan.Steps[len(plan.Steps)-1]; last.Candidates != len(aa) {
+		t.Fatalf("expected %d candidates after the last step, got %d", len(aa), last.Candidates)
+	}
+	if plan.Duration <= 0 {
+		t.Fatal("expected positive duration")
+	}
+}
+
+func TestGroupPlan(t *testing.T) {
+	aa := generate(testAccounts)
+	d := newDatastore(t, aa)
+
+	country := pick(rand.New(rand.NewSource(1)), aa, countryOf)
+	plan := datastore.NewPlan("country=" + country)
+	groups, err := d.GroupAccountsPlan(plan, []datastore.GroupKeyFunc{datastore.GroupCity()}, false, 2, d.FilterCountry(datastore.Equal(country)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if plan.Seed != "byCountry" {
+		t.Fatalf("expected byCountry seed, got %s", plan.Seed)
+	}
+	if len(plan.Steps) != 2 || plan.Steps[1].Predicate != "group" {
+		t.Fatalf("expected filter and group steps, got %s", plan)
+	}
+	if plan.Groups < len(groups) {
+		t.Fatalf("expected at least %d groups, got %d", len(groups), plan.Groups)
+	}
+}
+
+func TestPlanSeed(t *testing.T) {
+	for predicate, seed := range map[string]string{
+		"email_domain=mail.ru":      "byEmail",
+		"premium_now=1":             "premiumStart",
+		"premium_null=1":            "noPremium",
+		"interests_any=Пиво,Футбол": "byInterest",
+		"joined=2015":               "byJoin",
+	} {
+		if s := datastore.NewPlan(predicate).Seed; s != seed {
+			t.Errorf("%s: expected %s seed, got %s", predicate, seed, s)
+		}
+	}
+	if s := datastore.NewPlan().Seed; s != "ordered" {
+		t.Errorf("expected ordered seed without filters, got %s", s)
+	}
+}
+
+func TestEstimate(t *testing.T) {
+	aa := generate(testAccounts)
+	d := newDatastore(t, aa)
+
+	r := rand.New(rand.NewSource(1))
+	city := pick(r, aa, cityOf)
+	names := pick(r, aa, fnameOf) + "," + pick(r, aa, fnameOf)
+	interest := pick(r, aa, interestOf(r))
+
+	// the first step is estimated exactly from index sizes.
+	for predicate, filter := range map[string]datastore.FilterFunc{
+		"city_eq=" + city:                d.FilterCity(datastore.Equal(city)),
+		"city_null=1":                    d.FilterCity(datastore.Null("1")),
+		"status_neq=заняты":              d.FilterStatus(datastore.NotEqual("заняты")),
+		"fname_any=" + names:             d.FilterFName(datastore.Any(names)),
+		"interests_contains=" + interest: d.FilterInterestsContains([]byte(interest)),
+		"premium_null=0":                 d.FilterPremiumNull("0"),
+	} {
+		plan := datastore.NewPlan(predicate)
+		if _, err := d.FilterAccountsPlan(plan, -1, filter); err != nil {
+			t.Fatal(err)
+		}
+		d.Estimate(plan)
+
+		if s := plan.Steps[0]; s.Estimated != s.Candidates {
+			t.Errorf("%s: estimated %d, actual %d", predicate, s.Estimated, s.Candidates)
+		}
+	}
+
+	plan := datastore.NewPlan("birth_year=1990", "sex_eq=m")
+	if _, err := d.FilterAccountsPlan(plan, -1, d.FilterBirth(mustCompareDates(datastore.Year("1990"))), d.FilterSex(datastore.Equal("m"))); err != nil {
+		t.Fatal(err)
+	}
+	d.Estimate(plan)
+	if plan.Steps[0].Estimated != -1 {
+		t.Errorf("expected no estimate for birth_year, got %d", plan.Steps[0].Estimated)
+	}
+	if plan.Steps[1].Estimated < 0 {
+		t.Errorf("expected an estimate for sex_eq, got %d", plan.Steps[1].Estimated)
+	}
+}
@@ -0,0 +1,108 @@
+package datastore
+
+import (
+	"sync"
+)
+
+// Loading stages.
+const (
+	StageStarting  = "starting"
+	StageSnapshot  = "snapshot"
+	StageImporting = "importing"
+	StageParsing   = "parsing"
+	StageReplaying = "replaying"
+	StageIndexing  = "indexing"
+	StageReady     = "ready"
+	StageFailed    = "failed"
+)
+
+// ProgressState is a state of datastore loading.
+type ProgressState struct {
+	Stage string
+	// Files is a number of parsed data files out of TotalFiles.
+	Files      int
+	TotalFiles int
+	// Accounts is a number of loaded accounts.
+	Accounts int
+	// Err is a loading error if the stage is failed.
+	Err error
+}
+
+// Progress tracks datastore loading, it is safe for concurrent use.
+type Progress struct {
+	mu    sync.Mutex
+	state ProgressState
+}
+
+// NewProgress returns a progress at the starting stage.
+func NewProgress() *Progress {
+	return &Progress{
+		state: ProgressState{
+			Stage: StageStarting,
+		},
+	}
+}
+
+// WithProgress reports loading progress to p.
+func WithProgress(p *Progress) Option {
+	return func(d *Datastore) {
+		d.progress = p
+	}
+}
+
+// State returns the current loading state.
+func (p *Progress) State() ProgressState {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.state
+}
+
+// Ready returns true if the datastore is loaded.
+func (p *Progress) Ready() bool {
+	return p.State().Stage == StageReady
+}
+
+func (p *Progress) update(f func(s *ProgressState)) {
+	if p == nil {
+		return
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	f(&p.state)
+}
+
+func (p *Progress) stage(stage string) {
+	p.update(func(s *ProgressState) {
+		s.Stage = stage
+	})
+}
+
+func (p *Progress) files(total int) {
+	p.update(func(s *ProgressState) {
+		s.Stage = StageParsing
+		s.TotalFiles = total
+	})
+}
+
+func (p *Progress) parsed(accounts int) {
+	p.update(func(s *ProgressState) {
+		s.Files++
+		s.Accounts += accounts
+	})
+}
+
+func (p *Progress) loaded(accounts int) {
+	p.update(func(s *ProgressState) {
+		s.Accounts = accounts
+	})
+}
+
+func (p *Progress) fail(err error) {
+	p.update(func(s *ProgressState) {
+		s.Stage = StageFailed
+		s.Err = err
+	})
+}
@@ -0,0 +1,155 @@
+package datastore
+
+import (
+	"bufio"
+	"encoding/gob"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/ngalayko/highloadcup/app/accounts"
+)
+
+// snapshotVersion is increased on every incompatible snapshot format change.
+const snapshotVersion = 2
+
+// source identifies imported data a snapshot is built from.
+type source struct {
+	Size    int64
+	ModTime time.Time
+}
+
+// snapshotHeader precedes accounts in a snapshot file, so freshness is
+// checked without decoding them.
+type snapshotHeader struct {
+	Version int
+	// Sequence is a sequence number of the last applied mutation.
+	Sequence uint64
+	Source   source
+}
+
+// WithSnapshot makes datastore load accounts from a snapshot at path
+// if it is built from the same imported data, and dump them there after
+// load and after writes.
+func WithSnapshot(path string) Option {
+	return func(d *Datastore) {
+		d.snapshotPath = path
+	}
+}
+
+// importedSource returns size and modification time of imported data.
+func (d *Datastore) importedSource() (source, error) {
+	size, err := d.importer.Size()
+	if err != nil {
+		return source{}, err
+	}
+	modTime, err := d.importer.ModTime()
+	if err != nil {
+		return source{}, err
+	}
+	return source{Size: size, ModTime: modTime}, nil
+}
+
+// snapshotFresh returns true if snapshot exists and is built from
+// the same imported data.
+func (d *Datastore) snapshotFresh() (bool, error) {
+	file, err := os.Open(d.snapshotPath)
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	defer file.Close()
+
+	h := &snapshotHeader{}
+	if err := gob.NewDecoder(bufio.NewReader(file)).Decode(h); err != nil {
+		d.log.Error("can't read snapshot header: %s", err)
+		return false, nil
+	}
+	return h.Version == snapshotVersion &&
+		h.Source.Size == d.source.Size &&
+		h.Source.ModTime.Equal(d.source.ModTime), nil
+}
+
+// readSnapshot reads accounts and the last applied mutation sequence number
+// from a snapshot file.
+func readSnapshot(path string) ([]*accounts.Account, uint64, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer file.Close()
+
+	dec := gob.NewDecoder(bufio.NewReader(file))
+	h := &snapshotHeader{}
+	if err := dec.Decode(h); err != nil {
+		return nil, 0, err
+	}
+	if h.Version != snapshotVersion {
+		return nil, 0, fmt.Errorf("unsupported snapshot version: %d", h.Version)
+	}
+	aa := []*accounts.Account{}
+	if err := dec.Decode(&aa); err != nil {
+		return nil, 0, err
+	}
+	return aa, h.Sequence, nil
+}
+
+// writeSnapshot atomically replaces a snapshot file with given accounts
+// built from imported data src.
+func writeSnapshot(path string, src source, aa []*accounts.Account, seq uint64) error {
+	file, err := os.Create(filepath.Join(filepath.Dir(path), "."+filepath.Base(path)+".tmp"))
+	if err != nil {
+		return err
+	}
+	defer os.Remove(file.Name())
+	defer file.Close()
+
+	w := bufio.NewWriter(file)
+	enc := gob.NewEncoder(w)
+	if err := enc.Encode(&snapshotHeader{
+		Version:  snapshotVersion,
+		Sequence: seq,
+		Source:   src,
+	}); err != nil {
+		return err
+	}
+	if err := enc.Encode(aa); err != nil {
+		return err
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	if err := file.Sync(); err != nil {
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	return os.Rename(file.Name(), path)
+}
+
+// saveSnapshot dumps accounts to the snapshot file if it is enabled,
+// unless a snapshot of a later mutation is already saved.
+// Must be called with d.snapshotMu held.
+func (d *Datastore) saveSnapshot(aa []*accounts.Account, seq uint64) {
+	if d.snapshotPath == "" {
+		return
+	}
+	if seq != 0 && seq <= d.savedSeq {
+		return
+	}
+	if err := writeSnapshot(d.snapshotPath, d.source, aa, seq); err != nil {
+		d.log.Error("can't write snapshot: %s", err)
+		return
+	}
+	d.savedSeq = seq
+	d.log.Info("saved %d accounts to snapshot %s", len(aa), d.snapshotPath)
+
+	d.mu.Lock()
+	wal := d.wal
+	d.mu.Unlock()
+	d.compactWAL(wal, seq)
+}
@@ -0,0 +1,96 @@
+package datastore_test
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"log"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ngalayko/highloadcup/app/datastore"
+	"github.com/ngalayko/highloadcup/app/logger"
+)
+
+func TestSnapshot(t *testing.T) {
+	log.SetOutput(ioutil.Discard)
+
+	data, err := json.Marshal(map[string]interface{}{
+		"accounts": generate(50),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	modTime := time.Date(2018, 12, 20, 0, 0, 0, 0, time.UTC)
+
+	for _, c := range []struct {
+		name     string
+		importer *memImporter
+		snapshot string
+		corrupt  bool
+		restored bool
+	}{
+		{
+			name:     "same data",
+			importer: &memImporter{data: data, modTime: modTime},
+			restored: true,
+		},
+		{
+			name:     "modified data",
+			importer: &memImporter{data: data, modTime: modTime.Add(time.Second)},
+		},
+		{
+			name:     "older data",
+			importer: &memImporter{data: data, modTime: modTime.Add(-time.Second)},
+		},
+		{
+			name:     "resized data",
+			importer: &memImporter{data: append(data, ' '), modTime: modTime},
+		},
+		{
+			name:     "missing snapshot",
+			importer: &memImporter{data: data, modTime: modTime},
+			snapshot: "missing",
+		},
+		{
+			name:     "corrupt snapshot",
+			importer: &memImporter{data: data, modTime: modTime},
+			corrupt:  true,
+		},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			dir := t.TempDir()
+			opts := []datastore.Option{
+				datastore.WithNow(testNow),
+				datastore.WithRebuildDelay(time.Hour),
+				datastore.WithSnapshot(filepath.Join(dir, "snapshot")),
+			}
+			d, err := datastore.New(logger.New(), &memImporter{data: data, modTime: modTime}, opts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := d.NewAccount([]byte(`{"id":100000,"email":"new@mail.ru","sex":"m","status":"свободны"}`)); err != nil {
+				t.Fatal(err)
+			}
+			if err := d.Close(); err != nil {
+				t.Fatal(err)
+			}
+
+			if c.corrupt {
+				if err := ioutil.WriteFile(filepath.Join(dir, "snapshot"), []byte("snapshot"), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if c.snapshot != "" {
+				opts = append(opts, datastore.WithSnapshot(filepath.Join(dir, c.snapshot)))
+			}
+			restored, err := datastore.New(logger.New(), c.importer, opts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, exists := restored.Account(100000); exists != c.restored {
+				t.Fatalf("expected a written account restored from the snapshot to be %t", c.restored)
+			}
+		})
+	}
+}
@@ -0,0 +1,40 @@
+package datastore
+
+// Stats holds datastore sizes.
+type Stats struct {
+	// Accounts is a number of primary account records.
+	Accounts int
+	// Pending is a number of writes not yet reflected in indexes.
+	Pending int
+	// Buckets is a number of keys by index name.
+	Buckets map[string]int
+}
+
+// Stats returns current datastore sizes.
+func (d *Datastore) Stats() *Stats {
+	d.mu.Lock()
+	s := &Stats{
+		Accounts: len(d.byID),
+		Pending:  len(d.pending),
+	}
+	d.mu.Unlock()
+
+	idx := d.index()
+	s.Buckets = map[string]int{
+		"bySex":        len(idx.bySex),
+		"byEmail":      len(idx.byEmail),
+		"byStatus":     len(idx.byStatus),
+		"byFName":      len(idx.byFName),
+		"bySName":      len(idx.bySName),
+		"byPhone":      len(idx.byPhone),
+		"byCountry":    len(idx.byCountry),
+		"byCity":       len(idx.byCity),
+		"byBirth":      len(idx.byBirth),
+		"byJoin":       len(idx.byJoin),
+		"byInterest":   len(idx.byInterest),
+		"likedBy":      len(idx.likedBy),
+		"premiumStart": len(idx.premiumStart),
+		"premiumEnd":   len(idx.premiumEnd),
+	}
+	return s
+}
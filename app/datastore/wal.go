@@ -0,0 +1,370 @@
+package datastore
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// SyncPolicy defines when the write-ahead log is synced to disk.
+type SyncPolicy struct {
+	// Always syncs after every write.
+	Always bool
+	// Interval syncs periodically if not zero.
+	Interval time.Duration
+}
+
+// ParseSyncPolicy parses sync policy from "always", "never" or a sync interval.
+func ParseSyncPolicy(s string) (SyncPolicy, error) {
+	switch s {
+	case "always":
+		return SyncPolicy{Always: true}, nil
+	case "never", "":
+		return SyncPolicy{}, nil
+	}
+
+	interval, err := time.ParseDuration(s)
+	if err != nil {
+		return SyncPolicy{}, fmt.Errorf("invalid sync policy '%s': %s", s, err)
+	}
+	return SyncPolicy{Interval: interval}, nil
+}
+
+// WithWAL enables write-ahead log of accepted mutations at path.
+// The log is replayed on startup after the initial import.
+func WithWAL(path string, policy SyncPolicy) Option {
+	return func(d *Datastore) {
+		d.walPath = path
+		d.walSync = policy
+	}
+}
+
+// wal is an append-only log of mutations, one json per line.
+type wal struct {
+	mu     sync.Mutex
+	path   string
+	file   *os.File
+	policy SyncPolicy
+	dirty  bool
+	// size is a size of fully written mutations.
+	size int64
+	done chan struct{}
+
+	// syncMu serializes commits, synced is a size of mutations synced
+	// to disk, so concurrent writes waiting for a sync share it.
+	syncMu sync.Mutex
+	synced int64
+}
+
+// openWAL opens a log for appending, truncating it to size bytes of valid
+// mutations first, so a torn tail is not continued by new mutations.
+func openWAL(path string, policy SyncPolicy, size int64) (*wal, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, err
+	}
+	if err := file.Truncate(size); err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	w := &wal{
+		path:   path,
+		file:   file,
+		policy: policy,
+		size:   size,
+		done:   make(chan struct{}),
+		synced: size,
+	}
+	if policy.Interval > 0 {
+		go w.syncLoop()
+	}
+	return w, nil
+}
+
+func (w *wal) syncLoop() {
+	ticker := time.NewTicker(w.policy.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			w.mu.Lock()
+			w.sync()
+			w.mu.Unlock()
+		case <-w.done:
+			return
+		}
+	}
+}
+
+// sync must be called with w.mu held.
+func (w *wal) sync() error {
+	if !w.dirty {
+		return nil
+	}
+	w.dirty = false
+	return w.file.Sync()
+}
+
+// Append writes a mutation to the log without syncing it and returns
+// a size of the log including the mutation, it is passed to Commit.
+// If writing fails, the log is truncated back so the mutation is not
+// replayed.
+func (w *wal) Append(m *Mutation) (int64, error) {
+	data, err := json.Marshal(m)
+	if err != nil {
+		return 0, err
+	}
+	data = append(data, '\n')
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if _, err := w.file.Write(data); err != nil {
+		w.file.Truncate(w.size)
+		return 0, err
+	}
+
+	w.dirty = true
+	w.size += int64(len(data))
+	return w.size, nil
+}
+
+// Commit syncs the log up to size if the policy syncs every write.
+// Mutations appended while a sync is running are synced together
+// by the next one.
+func (w *wal) Commit(size int64) error {
+	if !w.policy.Always {
+		return nil
+	}
+
+	w.syncMu.Lock()
+	defer w.syncMu.Unlock()
+
+	w.mu.Lock()
+	if w.synced >= size {
+		w.mu.Unlock()
+		return nil
+	}
+	written := w.size
+	w.dirty = false
+	w.mu.Unlock()
+
+	if err := w.file.Sync(); err != nil {
+		return err
+	}
+
+	w.mu.Lock()
+	w.synced = written
+	w.mu.Unlock()
+	return nil
+}
+
+// Compact drops mutations up to seq from the log once a snapshot
+// covers them. Mutations after seq are copied to a new log replacing
+// the current one.
+func (w *wal) Compact(seq uint64) error {
+	// written mutations don't change, so the covered part is found
+	// without blocking appends.
+	covered, err := walSize(w.path, seq)
+	if err != nil || covered == 0 {
+		return err
+	}
+
+	w.syncMu.Lock()
+	defer w.syncMu.Unlock()
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	src, err := os.Open(w.path)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	if _, err := src.Seek(covered, io.SeekStart); err != nil {
+		return err
+	}
+
+	tmp, err := os.Create(filepath.Join(filepath.Dir(w.path), "."+filepath.Base(w.path)+".tmp"))
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	if _, err := io.CopyN(tmp, src, w.size-covered); err != nil {
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), w.path); err != nil {
+		return err
+	}
+
+	file, err := os.OpenFile(w.path, os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	w.file.Close()
+	w.file = file
+	w.size -= covered
+	w.synced = w.size
+	w.dirty = false
+	return nil
+}
+
+// walSize returns a size of mutations up to seq at the start of a log.
+func walSize(path string, seq uint64) (int64, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+
+	size := int64(0)
+	r := bufio.NewReader(file)
+	for {
+		line, err := r.ReadBytes('\n')
+		if err == io.EOF {
+			return size, nil
+		}
+		if err != nil {
+			return 0, err
+		}
+
+		m := &struct {
+			Seq uint64 `json:"seq"`
+		}{}
+		if err := json.Unmarshal(line, m); err != nil || m.Seq > seq {
+			return size, nil
+		}
+		size += int64(len(line))
+	}
+}
+
+// compactWAL compacts a log up to seq covered by a saved snapshot.
+func (d *Datastore) compactWAL(w *wal, seq uint64) {
+	if w == nil || seq == 0 {
+		return
+	}
+	if err := w.Compact(seq); err != nil {
+		d.log.Error("can't compact write-ahead log: %s", err)
+	}
+}
+
+// Close syncs and closes the log.
+func (w *wal) Close() error {
+	close(w.done)
+
+	w.syncMu.Lock()
+	defer w.syncMu.Unlock()
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.dirty = true
+	if err := w.sync(); err != nil {
+		return err
+	}
+	w.synced = w.size
+	return w.file.Close()
+}
+
+// readWAL reads mutations from a log at path and returns a size of valid
+// mutations. Reading stops at a line that is not a complete mutation,
+// which is a tail partially written before a crash.
+func readWAL(path string) ([]*Mutation, int64, error) {
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, 0, nil
+	}
+	if err != nil {
+		return nil, 0, err
+	}
+	defer file.Close()
+
+	mm := []*Mutation{}
+	size := int64(0)
+	r := bufio.NewReader(file)
+	for {
+		line, err := r.ReadBytes('\n')
+		if err == io.EOF {
+			return mm, size, nil
+		}
+		if err != nil {
+			return nil, 0, err
+		}
+
+		m := &Mutation{}
+		if err := json.Unmarshal(line, m); err != nil {
+			return mm, size, nil
+		}
+		mm = append(mm, m)
+		size += int64(len(line))
+	}
+}
+
+// replay applies mutations from the log that are newer than the loaded data
+// and returns a size of valid mutations in the log.
+// Must be called before indexes are built.
+func (d *Datastore) replay() (int, int64, error) {
+	mm, size, err := readWAL(d.walPath)
+	if err != nil {
+		return 0, 0, fmt.Errorf("can't read write-ahead log: %s", err)
+	}
+	if info, err := os.Stat(d.walPath); err == nil && info.Size() > size {
+		d.log.Error("write-ahead log has a torn tail after %d mutations, truncating %d bytes", len(mm), info.Size()-size)
+	}
+
+	replayed := 0
+	for _, m := range mm {
+		if m.Seq <= d.seq {
+			continue
+		}
+		commit, err := d.prepare(m)
+		if err != nil {
+			return replayed, size, fmt.Errorf("can't replay mutation %d: %s", m.Seq, err)
+		}
+		commit()
+		d.seq = m.Seq
+		replayed++
+	}
+	return replayed, size, nil
+}
+
+// Close applies pending writes to indexes saving a snapshot if it is
+// enabled, then flushes and closes datastore files. Writes after Close
+// fail with ErrClosed.
+func (d *Datastore) Close() error {
+	d.mu.Lock()
+	d.closed = true
+	if d.rebuild != nil {
+		d.rebuild.Stop()
+	}
+	d.mu.Unlock()
+
+	d.Rebuild()
+
+	// wait for a snapshot saved by a background rebuild.
+	d.snapshotMu.Lock()
+	d.snapshotMu.Unlock()
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.wal == nil {
+		return nil
+	}
+	err := d.wal.Close()
+	d.wal = nil
+	return err
+}
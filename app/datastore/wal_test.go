@@ -0,0 +1,229 @@
+package datastore_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ngalayko/highloadcup/app/datastore"
+)
+
+// write applies a new account, an update and likes.
+func write(t *testing.T, d *datastore.Datastore, id int64) {
+	itoa := func(id int64) string {
+		return strconv.FormatInt(id, 10)
+	}
+	if err := d.NewAccount([]byte(`{"id":` + itoa(id) + `,"email":"new` + itoa(id) + `@mail.ru","sex":"m","status":"свободны"}`)); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.UpdateAccount(id, []byte(`{"status":"заняты","city":"Москва"}`)); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.AddLikes([]byte(`{"likes":[{"likee":1,"liker":` + itoa(id) + `,"ts":1540000000}]}`)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestReplay(t *testing.T) {
+	log.SetOutput(ioutil.Discard)
+
+	aa := generate(50)
+	path := filepath.Join(t.TempDir(), "wal")
+	opts := []datastore.Option{
+		datastore.WithRebuildDelay(time.Hour),
+		datastore.WithWAL(path, datastore.SyncPolicy{Always: true}),
+	}
+
+	d := newDatastore(t, aa, opts...)
+	write(t, d, 100000)
+	expected := dump(t, d)
+
+	// the instance crashes without closing the log.
+	restored := newDatastore(t, aa, opts...)
+	if after := dump(t, restored); !bytes.Equal(expected, after) {
+		t.Fatal("replayed accounts differ from written")
+	}
+	a, _ := restored.Account(100000)
+	if a.Status != "заняты" || len(a.Likes) != 1 {
+		t.Fatalf("unexpected replayed account %+v", a)
+	}
+}
+
+func TestReplayConcurrent(t *testing.T) {
+	log.SetOutput(ioutil.Discard)
+
+	aa := generate(50)
+	path := filepath.Join(t.TempDir(), "wal")
+	opts := []datastore.Option{
+		datastore.WithRebuildDelay(time.Hour),
+		datastore.WithWAL(path, datastore.SyncPolicy{Always: true}),
+	}
+
+	// concurrent writes share log syncs.
+	d := newDatastore(t, aa, opts...)
+	wg := &sync.WaitGroup{}
+	for i := int64(0); i < 20; i++ {
+		wg.Add(1)
+		go func(id int64) {
+			defer wg.Done()
+			write(t, d, id)
+		}(100000 + i)
+	}
+	wg.Wait()
+	expected := dump(t, d)
+
+	restored := newDatastore(t, aa, opts...)
+	if after := dump(t, restored); !bytes.Equal(expected, after) {
+		t.Fatal("replayed accounts differ from concurrently written")
+	}
+}
+
+func TestReplayTornTail(t *testing.T) {
+	log.SetOutput(ioutil.Discard)
+
+	aa := generate(50)
+	path := filepath.Join(t.TempDir(), "wal")
+	opts := []datastore.Option{
+		datastore.WithRebuildDelay(time.Hour),
+		datastore.WithWAL(path, datastore.SyncPolicy{Always: true}),
+	}
+
+	d := newDatastore(t, aa, opts...)
+	write(t, d, 100000)
+	if err := d.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// a mutation is partially written before a crash.
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := file.WriteString(`{"seq":4,"type":1,"data":{"id":1000`); err != nil {
+		t.Fatal(err)
+	}
+	file.Close()
+
+	restored := newDatastore(t, aa, opts...)
+	if _, exists := restored.Account(100000); !exists {
+		t.Fatal("expected mutations before a torn tail to be replayed")
+	}
+	write(t, restored, 100001)
+	if err := restored.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// mutations written after a torn tail are replayed too.
+	restored = newDatastore(t, aa, opts...)
+	for _, id := range []int64{100000, 100001} {
+		a, exists := restored.Account(id)
+		if !exists || len(a.Likes) != 1 {
+			t.Fatalf("expected account %d to be replayed, got %+v", id, a)
+		}
+	}
+}
+
+func TestReplaySnapshot(t *testing.T) {
+	log.SetOutput(ioutil.Discard)
+
+	dir := t.TempDir()
+	opts := []datastore.Option{
+		datastore.WithRebuildDelay(time.Hour),
+		datastore.WithSnapshot(filepath.Join(dir, "snapshot")),
+		datastore.WithWAL(filepath.Join(dir, "wal"), datastore.SyncPolicy{Always: true}),
+	}
+
+	aa := generate(50)
+	d := newDatastore(t, aa, opts...)
+	write(t, d, 100000)
+	// the snapshot has the first writes, the log has all of them.
+	d.Rebuild()
+	write(t, d, 100001)
+	expected := dump(t, d)
+
+	restored := newDatastore(t, aa, opts...)
+	if after := dump(t, restored); !bytes.Equal(expected, after) {
+		t.Fatal("accounts restored from a snapshot and a log differ from written")
+	}
+}
+
+// walSeqs returns sequence numbers of mutations in a log.
+func walSeqs(t *testing.T, path string) []uint64 {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	seqs := []uint64{}
+	for _, line := range bytes.Split(bytes.TrimSpace(data), []byte("\n")) {
+		if len(line) == 0 {
+			continue
+		}
+		m := &datastore.Mutation{}
+		if err := json.Unmarshal(line, m); err != nil {
+			t.Fatal(err)
+		}
+		seqs = append(seqs, m.Seq)
+	}
+	return seqs
+}
+
+func TestCompact(t *testing.T) {
+	log.SetOutput(ioutil.Discard)
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "wal")
+	opts := []datastore.Option{
+		datastore.WithRebuildDelay(time.Hour),
+		datastore.WithSnapshot(filepath.Join(dir, "snapshot")),
+		datastore.WithWAL(path, datastore.SyncPolicy{Always: true}),
+	}
+
+	aa := generate(50)
+	d := newDatastore(t, aa, opts...)
+	write(t, d, 100000)
+	if seqs := walSeqs(t, path); !reflect.DeepEqual(seqs, []uint64{1, 2, 3}) {
+		t.Fatalf("expected mutations 1-3 in the log, got %v", seqs)
+	}
+
+	// the snapshot covers the log.
+	d.Rebuild()
+	if seqs := walSeqs(t, path); len(seqs) != 0 {
+		t.Fatalf("expected the log to be compacted, got %v", seqs)
+	}
+
+	write(t, d, 100001)
+	if seqs := walSeqs(t, path); !reflect.DeepEqual(seqs, []uint64{4, 5, 6}) {
+		t.Fatalf("expected mutations 4-6 after the snapshot, got %v", seqs)
+	}
+	expected := dump(t, d)
+
+	// the instance crashes, the snapshot and the compacted log restore
+	// all writes.
+	restored := newDatastore(t, aa, opts...)
+	if after := dump(t, restored); !bytes.Equal(expected, after) {
+		t.Fatal("accounts restored from a snapshot and a compacted log differ from written")
+	}
+	if seqs := walSeqs(t, path); len(seqs) != 0 {
+		t.Fatalf("expected the log replayed into a snapshot to be compacted, got %v", seqs)
+	}
+
+	write(t, restored, 100002)
+	if err := restored.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if seqs := walSeqs(t, path); len(seqs) != 0 {
+		t.Fatalf("expected the log to be compacted on close, got %v", seqs)
+	}
+	restored = newDatastore(t, aa, opts...)
+	if _, exists := restored.Account(100002); !exists {
+		t.Fatal("expected a written account to be restored after compaction")
+	}
+}
@@ -0,0 +1,219 @@
+package datastore
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/ngalayko/highloadcup/app/accounts"
+)
+
+// Write errors.
+var (
+	ErrNotFound = errors.New("account not found")
+	ErrExists   = errors.New("account already exists")
+	ErrEmail    = errors.New("email is already taken")
+	ErrLike     = errors.New("invalid like")
+	ErrNull     = errors.New("field can't be null")
+	ErrClosed   = errors.New("datastore is closed")
+)
+
+// MutationType is a type of a write.
+type MutationType byte
+
+// MutationType values.
+const (
+	MutationNew MutationType = iota + 1
+	MutationUpdate
+	MutationLikes
+)
+
+// Mutation is a single accepted write.
+type Mutation struct {
+	Seq  uint64          `json:"seq"`
+	Type MutationType    `json:"type"`
+	ID   int64           `json:"id,omitempty"`
+	Data json.RawMessage `json:"data"`
+}
+
+// NewAccount creates an account from json data.
+func (d *Datastore) NewAccount(data []byte) error {
+	return d.Apply(&Mutation{
+		Type: MutationNew,
+		Data: data,
+	})
+}
+
+// UpdateAccount updates fields present in json data of an account with id.
+func (d *Datastore) UpdateAccount(id int64, data []byte) error {
+	return d.Apply(&Mutation{
+		Type: MutationUpdate,
+		ID:   id,
+		Data: data,
+	})
+}
+
+// AddLikes adds likes from json data.
+func (d *Datastore) AddLikes(data []byte) error {
+	return d.Apply(&Mutation{
+		Type: MutationLikes,
+		Data: data,
+	})
+}
+
+// Apply validates a mutation, appends it to the write-ahead log if it is
+// enabled and applies it to primary account records. Secondary indexes
+// are rebuilt later in the background. The log is synced after the lock
+// is released, so concurrent writes share a sync.
+func (d *Datastore) Apply(m *Mutation) error {
+	d.mu.Lock()
+
+	if d.closed {
+		d.mu.Unlock()
+		return ErrClosed
+	}
+
+	commit, err := d.prepare(m)
+	if err != nil {
+		d.mu.Unlock()
+		return err
+	}
+
+	m.Seq = d.seq + 1
+	wal, size := d.wal, int64(0)
+	if wal != nil {
+		if size, err = wal.Append(m); err != nil {
+			d.mu.Unlock()
+			return fmt.Errorf("can't append mutation %d to write-ahead log: %s", m.Seq, err)
+		}
+	}
+	commit()
+	d.seq = m.Seq
+
+	d.pending = append(d.pending, m)
+	d.scheduleRebuild()
+	d.mu.Unlock()
+
+	if wal == nil {
+		return nil
+	}
+	if err := wal.Commit(size); err != nil {
+		return fmt.Errorf("can't sync mutation %d to write-ahead log: %s", m.Seq, err)
+	}
+	return nil
+}
+
+// prepare validates a mutation and returns a function applying it to
+// primary account records. Must be called with d.mu held.
+func (d *Datastore) prepare(m *Mutation) (func(), error) {
+	switch m.Type {
+	case MutationNew:
+		return d.prepareNew(m.Data)
+	case MutationUpdate:
+		return d.prepareUpdate(m.ID, m.Data)
+	case MutationLikes:
+		return d.prepareLikes(m.Data)
+	default:
+		return nil, fmt.Errorf("unknown mutation type: %d", m.Type)
+	}
+}
+
+func (d *Datastore) prepareNew(data []byte) (func(), error) {
+	a := &accounts.Account{}
+	if err := json.Unmarshal(data, a); err != nil {
+		return nil, err
+	}
+	if err := a.Validate(); err != nil {
+		return nil, err
+	}
+	if _, exists := d.byID[a.ID]; exists {
+		return nil, ErrExists
+	}
+	if _, taken := d.emails[a.Email]; taken {
+		return nil, ErrEmail
+	}
+
+	prepare(a)
+	return func() {
+		d.byID[a.ID] = a
+		d.emails[a.Email] = a.ID
+	}, nil
+}
+
+func (d *Datastore) prepareUpdate(id int64, data []byte) (func(), error) {
+	old, exists := d.byID[id]
+	if !exists {
+		return nil, ErrNotFound
+	}
+
+	// null would keep an old value, but the contest rejects it.
+	fields := map[string]json.RawMessage{}
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, err
+	}
+	for _, value := range fields {
+		if string(value) == "null" {
+			return nil, ErrNull
+		}
+	}
+
+	a := old.Clone()
+	if err := json.Unmarshal(data, a); err != nil {
+		return nil, err
+	}
+	a.ID = id
+	if err := a.Validate(); err != nil {
+		return nil, err
+	}
+	if owner, taken := d.emails[a.Email]; taken && owner != id {
+		return nil, ErrEmail
+	}
+
+	prepare(a)
+	return func() {
+		delete(d.emails, old.Email)
+		d.byID[id] = a
+		d.emails[a.Email] = id
+	}, nil
+}
+
+func (d *Datastore) prepareLikes(data []byte) (func(), error) {
+	likes := &accounts.NewLikes{}
+	if err := json.Unmarshal(data, likes); err != nil {
+		return nil, err
+	}
+
+	for _, like := range likes.Likes {
+		if like == nil {
+			return nil, ErrLike
+		}
+		if _, exists := d.byID[like.Liker]; !exists {
+			return nil, ErrNotFound
+		}
+		if _, exists := d.byID[like.Likee]; !exists {
+			return nil, ErrNotFound
+		}
+	}
+
+	changed := make(map[int64]*accounts.Account, len(likes.Likes))
+	for _, like := range likes.Likes {
+		a, ok := changed[like.Liker]
+		if !ok {
+			a = d.byID[like.Liker].Clone()
+			changed[like.Liker] = a
+		}
+		a.Likes = append(a.Likes, &accounts.Like{
+			ID:        like.Likee,
+			Timestamp: like.Timestamp,
+		})
+	}
+
+	for _, a := range changed {
+		prepare(a)
+	}
+	return func() {
+		for id, a := range changed {
+			d.byID[id] = a
+		}
+	}, nil
+}
@@ -0,0 +1,180 @@
+package datastore_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"log"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/ngalayko/highloadcup/app/accounts"
+	"github.com/ngalayko/highloadcup/app/datastore"
+)
+
+func dump(t *testing.T, d *datastore.Datastore) []byte {
+	data, err := json.Marshal(d.Accounts())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func FuzzNewAccount(f *testing.F) {
+	f.Add([]byte(`{"id":100000,"email":"new@mail.ru","sex":"m","status":"свободны"}`))
+	f.Add([]byte(`{"id":100001,"email":"new@mail.ru","sex":"f","status":"заняты","interests":["Пиво"],"likes":[{"id":1,"ts":1}]}`))
+	f.Add([]byte(`{"id":1,"email":"taken@mail.ru","sex":"m","status":"свободны"}`))
+	f.Add([]byte(`{"id":100002,"email":"x@y","sex":"m","status":"свободны","likes":[null]}`))
+	f.Add([]byte(`{"id":"1"}`))
+
+	log.SetOutput(ioutil.Discard)
+	d := newDatastore(f, generate(50))
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		before := dump(t, d)
+
+		if err := d.NewAccount(data); err != nil {
+			if after := dump(t, d); !bytes.Equal(before, after) {
+				t.Fatalf("%s: rejected account changed accounts: %s", data, err)
+			}
+			return
+		}
+
+		a := &accounts.Account{}
+		if err := json.Unmarshal(data, a); err != nil {
+			t.Fatalf("%s: accepted account can't be decoded: %s", data, err)
+		}
+		stored, exists := d.Account(a.ID)
+		if !exists {
+			t.Fatalf("%s: accepted account is not stored", data)
+		}
+		if err := stored.Validate(); err != nil {
+			t.Fatalf("%s: stored account is invalid: %s", data, err)
+		}
+		if err := d.NewAccount(data); err == nil {
+			t.Fatalf("%s: account is accepted twice", data)
+		}
+	})
+}
+
+func FuzzAddLikes(f *testing.F) {
+	f.Add([]byte(`{"likes":[{"likee":1,"liker":2,"ts":3}]}`))
+	f.Add([]byte(`{"likes":[{"likee":1,"liker":100000,"ts":3}]}`))
+	f.Add([]byte(`{"likes":[null]}`))
+	f.Add([]byte(`{"likes":[]}`))
+
+	log.SetOutput(ioutil.Discard)
+	d := newDatastore(f, generate(50))
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		before := dump(t, d)
+
+		if err := d.AddLikes(data); err != nil {
+			if after := dump(t, d); !bytes.Equal(before, after) {
+				t.Fatalf("%s: rejected likes changed accounts: %s", data, err)
+			}
+			return
+		}
+
+		likes := &accounts.NewLikes{}
+		if err := json.Unmarshal(data, likes); err != nil {
+			t.Fatalf("%s: accepted likes can't be decoded: %s", data, err)
+		}
+		for _, like := range likes.Likes {
+			liker, exists := d.Account(like.Liker)
+			if !exists {
+				t.Fatalf("%s: like of unknown account %d is accepted", data, like.Liker)
+			}
+			if !liker.LikesMap[strconv.FormatInt(like.Likee, 10)] {
+				t.Fatalf("%s: like of %d is not stored", data, like.Likee)
+			}
+		}
+	})
+}
+
+func TestDeferredRebuild(t *testing.T) {
+	log.SetOutput(ioutil.Discard)
+
+	d := newDatastore(t, generate(50), datastore.WithRebuildDelay(200*time.Millisecond))
+	if err := d.NewAccount([]byte(`{"id":100000,"email":"new@mail.ru","sex":"m","status":"свободны"}`)); err != nil {
+		t.Fatal(err)
+	}
+	if d.Pending() != 1 {
+		t.Fatalf("expected indexes not to be rebuilt on write, got %d pending writes", d.Pending())
+	}
+
+	for deadline := time.Now().Add(5 * time.Second); d.Pending() != 0; time.Sleep(10 * time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatal("indexes are not rebuilt in background")
+		}
+	}
+	aa, err := d.FilterAccounts(-1, d.FilterEmail(datastore.Equal("new@mail.ru")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, exists := aa[100000]; !exists {
+		t.Fatal("expected a written account in rebuilt indexes")
+	}
+}
+
+func TestFilterIndexPerQuery(t *testing.T) {
+	log.SetOutput(ioutil.Discard)
+
+	d := newDatastore(t, generate(50), datastore.WithRebuildDelay(time.Hour))
+
+	// filters are built before indexes are rebuilt and applied after.
+	filter := d.FilterSex(datastore.Equal("m"))
+	if err := d.NewAccount([]byte(`{"id":100000,"email":"new@mail.ru","sex":"m","status":"свободны"}`)); err != nil {
+		t.Fatal(err)
+	}
+	d.Rebuild()
+
+	aa, err := d.FilterAccounts(-1, filter)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, exists := aa[100000]; !exists {
+		t.Fatal("expected a filter to use indexes loaded by the query")
+	}
+}
+
+func TestClose(t *testing.T) {
+	log.SetOutput(ioutil.Discard)
+
+	dir := t.TempDir()
+	snapshot := filepath.Join(dir, "snapshot")
+	opts := []datastore.Option{
+		datastore.WithRebuildDelay(time.Hour),
+		datastore.WithSnapshot(snapshot),
+		datastore.WithWAL(filepath.Join(dir, "wal"), datastore.SyncPolicy{}),
+	}
+	aa := generate(50)
+	d := newDatastore(t, aa, opts...)
+
+	if err := d.NewAccount([]byte(`{"id":100000,"email":"new@mail.ru","sex":"m","status":"свободны"}`)); err != nil {
+		t.Fatal(err)
+	}
+	if d.Pending() != 1 {
+		t.Fatalf("expected a pending write, got %d", d.Pending())
+	}
+
+	if err := d.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if d.Pending() != 0 {
+		t.Fatalf("expected pending writes to be applied on close, got %d", d.Pending())
+	}
+	if err := d.Close(); err != nil {
+		t.Fatalf("second close: %s", err)
+	}
+	if err := d.NewAccount([]byte(`{"id":100001,"email":"closed@mail.ru","sex":"m","status":"свободны"}`)); err != datastore.ErrClosed {
+		t.Fatalf("expected writes to be rejected after close, got %v", err)
+	}
+
+	restored := newDatastore(t, aa, datastore.WithSnapshot(snapshot))
+	if _, exists := restored.Account(100000); !exists {
+		t.Fatal("expected a written account to be restored from the snapshot")
+	}
+}
@@ -0,0 +1,108 @@
+package zip
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/ngalayko/highloadcup/app/accounts"
+)
+
+// accountsPerFile is a number of accounts in a single accounts_N.json file.
+const accountsPerFile = 10000
+
+// optionsFile is a name of a file with dataset options.
+const optionsFile = "options.txt"
+
+// Exporter exports data to a zip archive in the same layout zip importer reads.
+type Exporter struct {
+	w       io.Writer
+	options []byte
+}
+
+// Option is an exporter option.
+type Option func(*Exporter)
+
+// WithOptions writes options.txt of the dataset to the archive.
+func WithOptions(options []byte) Option {
+	return func(z *Exporter) {
+		z.options = options
+	}
+}
+
+// New creates zip exporter.
+func New(w io.Writer, opts ...Option) *Exporter {
+	z := &Exporter{
+		w: w,
+	}
+	for _, opt := range opts {
+		opt(z)
+	}
+	return z
+}
+
+// Write writes accounts to zip archive as accounts_N.json files,
+// and options.txt if it is set.
+func (z *Exporter) Write(aa []*accounts.Account) error {
+	zw := zip.NewWriter(z.w)
+
+	if z.options != nil {
+		fw, err := create(zw, optionsFile)
+		if err != nil {
+			return fmt.Errorf("can't write file '%s': %s", optionsFile, err)
+		}
+		if _, err := fw.Write(z.options); err != nil {
+			return fmt.Errorf("can't write file '%s': %s", optionsFile, err)
+		}
+	}
+
+	for n := 0; n*accountsPerFile < len(aa); n++ {
+		end := (n + 1) * accountsPerFile
+		if end > len(aa) {
+			end = len(aa)
+		}
+
+		name := fmt.Sprintf("accounts_%d.json", n+1)
+		if err := writeFile(zw, name, aa[n*accountsPerFile:end]); err != nil {
+			return fmt.Errorf("can't write file '%s': %s", name, err)
+		}
+	}
+
+	return zw.Close()
+}
+
+func create(zw *zip.Writer, name string) (io.Writer, error) {
+	return zw.CreateHeader(&zip.FileHeader{
+		Name:     name,
+		Method:   zip.Deflate,
+		Modified: time.Now(),
+	})
+}
+
+func writeFile(zw *zip.Writer, name string, aa []*accounts.Account) error {
+	fw, err := create(zw, name)
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.WriteString(fw, `{"accounts": [`); err != nil {
+		return err
+	}
+
+	enc := json.NewEncoder(fw)
+	for i, a := range aa {
+		if i != 0 {
+			if _, err := io.WriteString(fw, ","); err != nil {
+				return err
+			}
+		}
+		if err := enc.Encode(a); err != nil {
+			return err
+		}
+	}
+
+	_, err = io.WriteString(fw, "]}")
+	return err
+}
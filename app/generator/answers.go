@@ -0,0 +1,48 @@
+package generator
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// WriteAnswers writes queries in the answers file format:
+// method, uri, status code and an optional response body separated by tabs.
+func WriteAnswers(w io.Writer, qq []*Query) error {
+	for _, q := range qq {
+		line := fmt.Sprintf("%s\t%s\t%d", q.Method, q.URI, q.StatusCode)
+		if q.Response != nil {
+			body, err := json.Marshal(q.Response)
+			if err != nil {
+				return err
+			}
+			line += "\t" + string(body)
+		}
+		if _, err := fmt.Fprintln(w, line); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WriteAmmo writes queries in Yandex.Tank raw format the same way
+// as contest ammo files.
+func WriteAmmo(w io.Writer, qq []*Query) error {
+	for _, q := range qq {
+		request := &bytes.Buffer{}
+		fmt.Fprintf(request, "%s %s HTTP/1.1\r\n", q.Method, q.URI)
+		fmt.Fprintf(request, "Host: accounts.com\r\n")
+		fmt.Fprintf(request, "User-Agent: Technolab/1.0 (Docker; CentOS) Highload/1.0\r\n")
+		fmt.Fprintf(request, "Accept: */*\r\n")
+		fmt.Fprintf(request, "Connection: keep-alive\r\n\r\n")
+
+		if _, err := fmt.Fprintf(w, "%d %s\n", request.Len(), q.Tag); err != nil {
+			return err
+		}
+		if _, err := request.WriteTo(w); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,242 @@
+// Package generator generates synthetic accounts with distributions
+// similar to the contest data.
+package generator
+
+import (
+	"fmt"
+	"math/rand"
+	"strings"
+	"time"
+
+	"github.com/ngalayko/highloadcup/app/accounts"
+)
+
+const (
+	day  = 24 * 60 * 60
+	year = 365 * day
+
+	// maxLikes is a maximum number of likes of an account.
+	maxLikes = 70
+)
+
+var (
+	birthFrom  = time.Date(1950, 1, 1, 0, 0, 0, 0, time.UTC).Unix()
+	birthTo    = time.Date(2005, 1, 1, 0, 0, 0, 0, time.UTC).Unix()
+	joinedFrom = time.Date(2011, 1, 1, 0, 0, 0, 0, time.UTC).Unix()
+	joinedTo   = time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC).Unix()
+)
+
+// Config is a generator config.
+type Config struct {
+	// Accounts is a number of accounts to generate.
+	Accounts int
+	// Seed makes generated data reproducible.
+	Seed int64
+	// Now is the current time of the dataset, premium periods are
+	// generated around it.
+	Now int64
+}
+
+// Generator generates accounts.
+type Generator struct {
+	cfg    *Config
+	r      *rand.Rand
+	cities map[string][]string
+	// allCities are cities of all countries.
+	allCities []string
+	emails    map[string]bool
+	phones    map[string]bool
+}
+
+// New is a generator constructor.
+func New(cfg *Config) *Generator {
+	g := &Generator{
+		cfg:    cfg,
+		r:      rand.New(rand.NewSource(cfg.Seed)),
+		cities: map[string][]string{},
+		emails: make(map[string]bool, cfg.Accounts),
+		phones: make(map[string]bool, cfg.Accounts),
+	}
+
+	// every city belongs to a country, some of them have same named cities.
+	for _, prefix := range cityPrefixes {
+		for _, end := range cityEnds {
+			if g.r.Intn(3) == 0 {
+				continue
+			}
+			city := prefix + end
+			g.allCities = append(g.allCities, city)
+			for i := 1 + g.r.Intn(2); i > 0; i-- {
+				country := g.country()
+				g.cities[country] = append(g.cities[country], city)
+			}
+		}
+	}
+	return g
+}
+
+// Generate returns accounts with ids from 1 to cfg.Accounts.
+func (g *Generator) Generate() []*accounts.Account {
+	aa := make([]*accounts.Account, 0, g.cfg.Accounts)
+	for id := int64(1); id <= int64(g.cfg.Accounts); id++ {
+		aa = append(aa, g.account(id))
+	}
+
+	// popularity is a random order of accounts, first are liked more often.
+	popularity := g.r.Perm(len(aa))
+	for _, a := range aa {
+		g.like(a, aa, popularity)
+	}
+	return aa
+}
+
+func (g *Generator) account(id int64) *accounts.Account {
+	a := &accounts.Account{
+		ID:     id,
+		Email:  g.email(),
+		Sex:    "m",
+		Birth:  birthFrom + g.r.Int63n(birthTo-birthFrom),
+		Joined: joinedFrom + g.r.Int63n(joinedTo-joinedFrom),
+		Status: g.status(),
+	}
+	if g.r.Intn(2) == 0 {
+		a.Sex = "f"
+	}
+
+	if g.chance(91) {
+		a.FName = g.fname(a.Sex)
+	}
+	if g.chance(74) {
+		a.SName = g.sname(a.Sex)
+	}
+	if g.chance(41) {
+		a.Phone = g.phone()
+	}
+	if g.chance(81) {
+		a.Country = g.country()
+	}
+	if g.chance(62) {
+		a.City = g.city(a.Country)
+	}
+
+	// most of accounts have from 1 to 5 interests.
+	n := g.r.Intn(4) + g.r.Intn(4) + g.r.Intn(2)
+	for _, i := range g.r.Perm(len(interests))[:n] {
+		a.Interests = append(a.Interests, interests[i])
+	}
+
+	if g.chance(31) {
+		start := g.cfg.Now - g.r.Int63n(year)
+		a.Premium = &accounts.Premium{
+			Start:  start,
+			Finish: start + premiumDays[g.r.Intn(len(premiumDays))]*day,
+		}
+	}
+	return a
+}
+
+// like adds likes to an account, likes of popular accounts are more likely.
+func (g *Generator) like(a *accounts.Account, aa []*accounts.Account, popularity []int) {
+	n := g.r.Intn(maxLikes)
+	if n > len(aa)/2 {
+		n = len(aa) / 2
+	}
+
+	liked := make(map[int64]bool, n)
+	for len(liked) < n {
+		p := g.r.Float64()
+		likee := aa[popularity[int(p*p*float64(len(aa)))]]
+		if likee.ID == a.ID || liked[likee.ID] {
+			continue
+		}
+		liked[likee.ID] = true
+
+		from := a.Joined
+		if likee.Joined > from {
+			from = likee.Joined
+		}
+		ts := from
+		if g.cfg.Now > from {
+			ts += g.r.Int63n(g.cfg.Now - from)
+		}
+		a.Likes = append(a.Likes, &accounts.Like{
+			ID:        likee.ID,
+			Timestamp: ts,
+		})
+	}
+}
+
+// chance returns true with a probability of percent.
+func (g *Generator) chance(percent int) bool {
+	return g.r.Intn(100) < percent
+}
+
+func (g *Generator) pick(ss []string) string {
+	return ss[g.r.Intn(len(ss))]
+}
+
+func (g *Generator) status() string {
+	switch p := g.r.Intn(100); {
+	case p < 50:
+		return "свободны"
+	case p < 80:
+		return "заняты"
+	default:
+		return "всё сложно"
+	}
+}
+
+func (g *Generator) fname(sex string) string {
+	if sex == "f" {
+		return g.pick(femaleNames)
+	}
+	return g.pick(maleNames)
+}
+
+func (g *Generator) sname(sex string) string {
+	ends := maleSurnameEnds
+	if sex == "f" {
+		ends = femaleSurnameEnds
+	}
+	return g.pick(surnamePrefixes) + g.pick(surnameMiddles) + g.pick(ends)
+}
+
+func (g *Generator) country() string {
+	return g.pick(countryPrefixes) + g.pick(countryEnds)
+}
+
+// city returns a city of a country or any city if the country is empty.
+func (g *Generator) city(country string) string {
+	if cities := g.cities[country]; len(cities) != 0 {
+		return g.pick(cities)
+	}
+	return g.pick(g.allCities)
+}
+
+// email returns a unique email.
+func (g *Generator) email() string {
+	for {
+		local := &strings.Builder{}
+		for i := 3 + g.r.Intn(6); i > 0; i-- {
+			local.WriteString(g.pick(emailSyllables))
+		}
+		email := local.String() + "@" + g.pick(emailDomains)
+		if g.emails[email] {
+			continue
+		}
+		g.emails[email] = true
+		return email
+	}
+}
+
+// phone returns a unique phone.
+func (g *Generator) phone() string {
+	for {
+		phone := fmt.Sprintf("8(9%02d)%07d", g.r.Intn(100), g.r.Intn(10000000))
+		if g.phones[phone] {
+			continue
+		}
+		g.phones[phone] = true
+		return phone
+	}
+}
@@ -0,0 +1,464 @@
+package generator
+
+import (
+	"fmt"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ngalayko/highloadcup/app/accounts"
+	"github.com/ngalayko/highloadcup/app/datastore/oracle"
+)
+
+// Query is a generated request with an expected response.
+type Query struct {
+	// Tag groups queries of the same type in ammo.
+	Tag        string
+	Method     string
+	URI        string
+	StatusCode int
+	// Response is nil if no response body is expected.
+	Response interface{}
+}
+
+// QueryGenerator generates random valid queries for a dataset
+// with expected responses computed by the oracle.
+type QueryGenerator struct {
+	aa    []*accounts.Account
+	index *oracle.Index
+	now   int64
+	r     *rand.Rand
+	id    int
+}
+
+// NewQueryGenerator is a query generator constructor, now is the current
+// time of the dataset from options.txt.
+func NewQueryGenerator(aa []*accounts.Account, now int64, seed int64) *QueryGenerator {
+	return &QueryGenerator{
+		aa:    aa,
+		index: oracle.NewIndex(aa),
+		now:   now,
+		r:     rand.New(rand.NewSource(seed)),
+	}
+}
+
+// Generate returns n random queries of all types.
+func (g *QueryGenerator) Generate(n int) []*Query {
+	qq := make([]*Query, 0, n)
+	for len(qq) < n {
+		switch g.r.Intn(4) {
+		case 0:
+			qq = append(qq, g.Filter())
+		case 1:
+			qq = append(qq, g.Group())
+		case 2:
+			qq = append(qq, g.Recommend())
+		case 3:
+			qq = append(qq, g.Suggest())
+		}
+	}
+	return qq
+}
+
+// param is a query parameter with a predicate it stands for.
+type param struct {
+	name string
+	// field is an account field returned by a filter with the parameter.
+	field string
+	make  func(g *QueryGenerator, a *accounts.Account) (string, oracle.Predicate)
+}
+
+// filterParams are parameters of /accounts/filter/, values are taken from
+// a random account, so that filters mostly match something.
+var filterParams = []*param{
+	{"sex_eq", "sex", func(g *QueryGenerator, a *accounts.Account) (string, oracle.Predicate) {
+		return a.Sex, oracle.Eq("sex", a.Sex)
+	}},
+	{"email_domain", "", func(g *QueryGenerator, a *accounts.Account) (string, oracle.Predicate) {
+		domain := a.Email[strings.LastIndex(a.Email, "@")+1:]
+		return domain, oracle.EmailDomain(domain)
+	}},
+	{"email_lt", "", func(g *QueryGenerator, a *accounts.Account) (string, oracle.Predicate) {
+		prefix := a.Email[:1+g.r.Intn(2)]
+		return prefix, oracle.Lt("email", prefix)
+	}},
+	{"email_gt", "", func(g *QueryGenerator, a *accounts.Account) (string, oracle.Predicate) {
+		prefix := a.Email[:1+g.r.Intn(2)]
+		return prefix, oracle.Gt("email", prefix)
+	}},
+	{"status_eq", "status", func(g *QueryGenerator, a *accounts.Account) (string, oracle.Predicate) {
+		return a.Status, oracle.Eq("status", a.Status)
+	}},
+	{"status_neq", "status", func(g *QueryGenerator, a *accounts.Account) (string, oracle.Predicate) {
+		return a.Status, oracle.Neq("status", a.Status)
+	}},
+	{"fname_eq", "fname", func(g *QueryGenerator, a *accounts.Account) (string, oracle.Predicate) {
+		v := g.value(a.FName, a.Sex, fname)
+		return v, oracle.Eq("fname", v)
+	}},
+	{"fname_any", "fname", func(g *QueryGenerator, a *accounts.Account) (string, oracle.Predicate) {
+		names := g.value(a.FName, a.Sex, fname) + "," + g.value("", a.Sex, fname)
+		return names, oracle.Any("fname", names)
+	}},
+	{"fname_null", "fname", func(g *QueryGenerator, a *accounts.Account) (string, oracle.Predicate) {
+		null := g.null()
+		return null, oracle.Null("fname", null)
+	}},
+	{"sname_eq", "sname", func(g *QueryGenerator, a *accounts.Account) (string, oracle.Predicate) {
+		v := g.value(a.SName, a.Sex, sname)
+		return v, oracle.Eq("sname", v)
+	}},
+	{"sname_starts", "sname", func(g *QueryGenerator, a *accounts.Account) (string, oracle.Predicate) {
+		runes := []rune(g.value(a.SName, a.Sex, sname))
+		if len(runes) > 3 {
+			runes = runes[:3]
+		}
+		prefix := string(runes)
+		return prefix, oracle.Starts("sname", prefix)
+	}},
+	{"sname_null", "sname", func(g *QueryGenerator, a *accounts.Account) (string, oracle.Predicate) {
+		null := g.null()
+		return null, oracle.Null("sname", null)
+	}},
+	{"phone_code", "phone", func(g *QueryGenerator, a *accounts.Account) (string, oracle.Predicate) {
+		code := fmt.Sprintf("9%02d", g.r.Intn(100))
+		return code, oracle.PhoneCode(code)
+	}},
+	{"phone_null", "phone", func(g *QueryGenerator, a *accounts.Account) (string, oracle.Predicate) {
+		null := g.null()
+		return null, oracle.Null("phone", null)
+	}},
+	{"country_eq", "country", func(g *QueryGenerator, a *accounts.Account) (string, oracle.Predicate) {
+		v := g.value(a.Country, a.Sex, country)
+		return v, oracle.Eq("country", v)
+	}},
+	{"country_null", "country", func(g *QueryGenerator, a *accounts.Account) (string, oracle.Predicate) {
+		null := g.null()
+		return null, oracle.Null("country", null)
+	}},
+	{"city_eq", "city", func(g *QueryGenerator, a *accounts.Account) (string, oracle.Predicate) {
+		v := g.value(a.City, a.Sex, city)
+		return v, oracle.Eq("city", v)
+	}},
+	{"city_any", "city", func(g *QueryGenerator, a *accounts.Account) (string, oracle.Predicate) {
+		cities := g.value(a.City, a.Sex, city) + "," + g.value("", a.Sex, city)
+		return cities, oracle.Any("city", cities)
+	}},
+	{"city_null", "city", func(g *QueryGenerator, a *accounts.Account) (string, oracle.Predicate) {
+		null := g.null()
+		return null, oracle.Null("city", null)
+	}},
+	{"birth_lt", "birth", func(g *QueryGenerator, a *accounts.Account) (string, oracle.Predicate) {
+		return strconv.FormatInt(a.Birth, 10), oracle.BirthLt(a.Birth)
+	}},
+	{"birth_gt", "birth", func(g *QueryGenerator, a *accounts.Account) (string, oracle.Predicate) {
+		return strconv.FormatInt(a.Birth, 10), oracle.BirthGt(a.Birth)
+	}},
+	{"birth_year", "birth", func(g *QueryGenerator, a *accounts.Account) (string, oracle.Predicate) {
+		year := time.Unix(a.Birth, 0).UTC().Year()
+		return strconv.Itoa(year), oracle.BirthYear(year)
+	}},
+	{"interests_contains", "", func(g *QueryGenerator, a *accounts.Account) (string, oracle.Predicate) {
+		ii := g.interests(a)
+		return ii, oracle.InterestsContains(ii)
+	}},
+	{"interests_any", "", func(g *QueryGenerator, a *accounts.Account) (string, oracle.Predicate) {
+		ii := g.interests(a)
+		return ii, oracle.InterestsAny(ii)
+	}},
+	{"likes_contains", "", func(g *QueryGenerator, a *accounts.Account) (string, oracle.Predicate) {
+		ll := g.likes(a)
+		return ll, oracle.LikesContains(ll)
+	}},
+	{"premium_now", "premium", func(g *QueryGenerator, a *accounts.Account) (string, oracle.Predicate) {
+		return "1", oracle.PremiumNow(g.now)
+	}},
+	{"premium_null", "premium", func(g *QueryGenerator, a *accounts.Account) (string, oracle.Predicate) {
+		null := g.null()
+		return null, oracle.PremiumNull(null)
+	}},
+}
+
+// groupParams are filter parameters of /accounts/group/.
+var groupParams = []*param{
+	{"sex", "", func(g *QueryGenerator, a *accounts.Account) (string, oracle.Predicate) {
+		return a.Sex, oracle.Eq("sex", a.Sex)
+	}},
+	{"status", "", func(g *QueryGenerator, a *accounts.Account) (string, oracle.Predicate) {
+		return a.Status, oracle.Eq("status", a.Status)
+	}},
+	{"fname", "", func(g *QueryGenerator, a *accounts.Account) (string, oracle.Predicate) {
+		v := g.value(a.FName, a.Sex, fname)
+		return v, oracle.Eq("fname", v)
+	}},
+	{"sname", "", func(g *QueryGenerator, a *accounts.Account) (string, oracle.Predicate) {
+		v := g.value(a.SName, a.Sex, sname)
+		return v, oracle.Eq("sname", v)
+	}},
+	{"country", "", func(g *QueryGenerator, a *accounts.Account) (string, oracle.Predicate) {
+		v := g.value(a.Country, a.Sex, country)
+		return v, oracle.Eq("country", v)
+	}},
+	{"city", "", func(g *QueryGenerator, a *accounts.Account) (string, oracle.Predicate) {
+		v := g.value(a.City, a.Sex, city)
+		return v, oracle.Eq("city", v)
+	}},
+	{"birth", "", func(g *QueryGenerator, a *accounts.Account) (string, oracle.Predicate) {
+		year := time.Unix(a.Birth, 0).UTC().Year()
+		return strconv.Itoa(year), oracle.BirthYear(year)
+	}},
+	{"joined", "", func(g *QueryGenerator, a *accounts.Account) (string, oracle.Predicate) {
+		year := time.Unix(a.Joined, 0).UTC().Year()
+		return strconv.Itoa(year), oracle.JoinedYear(year)
+	}},
+	{"interests", "", func(g *QueryGenerator, a *accounts.Account) (string, oracle.Predicate) {
+		interest := g.pick(interests)
+		if len(a.Interests) != 0 {
+			interest = a.Interests[g.r.Intn(len(a.Interests))]
+		}
+		return interest, oracle.InterestsContains(interest)
+	}},
+	{"likes", "", func(g *QueryGenerator, a *accounts.Account) (string, oracle.Predicate) {
+		id := g.aa[g.r.Intn(len(g.aa))].ID
+		if len(a.Likes) != 0 {
+			id = a.Likes[g.r.Intn(len(a.Likes))].ID
+		}
+		like := strconv.FormatInt(id, 10)
+		return like, oracle.LikesContains(like)
+	}},
+}
+
+// groupKeys are keys of /accounts/group/.
+var groupKeys = []string{"sex", "status", "interests", "country", "city"}
+
+// Filter returns a random /accounts/filter/ query.
+func (g *QueryGenerator) Filter() *Query {
+	limit := 1 + g.r.Intn(50)
+	values, pp, fields := g.params(filterParams, 1+g.r.Intn(3))
+	values.Set("limit", strconv.Itoa(limit))
+
+	res := []map[string]interface{}{}
+	for _, a := range oracle.Filter(g.aa, limit, pp...) {
+		res = append(res, response(a, fields...))
+	}
+
+	return g.query("GET:/accounts/filter/", "/accounts/filter/", values, map[string]interface{}{
+		"accounts": res,
+	})
+}
+
+// Group returns a random /accounts/group/ query.
+func (g *QueryGenerator) Group() *Query {
+	limit := 1 + g.r.Intn(50)
+	values, pp, _ := g.params(groupParams, g.r.Intn(3))
+	values.Set("limit", strconv.Itoa(limit))
+
+	keys := []string{}
+	for _, i := range g.r.Perm(len(groupKeys))[:1+g.r.Intn(2)] {
+		keys = append(keys, groupKeys[i])
+	}
+	values.Set("keys", strings.Join(keys, ","))
+
+	desc := g.r.Intn(2) == 0
+	values.Set("order", "1")
+	if desc {
+		values.Set("order", "-1")
+	}
+
+	res := []map[string]interface{}{}
+	for _, group := range oracle.GroupBy(g.aa, keys, desc, limit, pp...) {
+		res = append(res, group.Response())
+	}
+
+	return g.query("GET:/accounts/group/", "/accounts/group/", values, map[string]interface{}{
+		"groups": res,
+	})
+}
+
+// Recommend returns a random /accounts/<id>/recommend/ query.
+func (g *QueryGenerator) Recommend() *Query {
+	return g.similar("recommend", func(id int64, limit int, pp []oracle.Predicate) ([]map[string]interface{}, bool) {
+		aa, exists := g.index.Recommend(id, g.now, limit, pp...)
+		res := []map[string]interface{}{}
+		for _, a := range aa {
+			res = append(res, response(a, "status", "fname", "sname", "birth", "premium"))
+		}
+		return res, exists
+	})
+}
+
+// Suggest returns a random /accounts/<id>/suggest/ query.
+func (g *QueryGenerator) Suggest() *Query {
+	return g.similar("suggest", func(id int64, limit int, pp []oracle.Predicate) ([]map[string]interface{}, bool) {
+		aa, exists := g.index.Suggest(id, limit, pp...)
+		res := []map[string]interface{}{}
+		for _, a := range aa {
+			res = append(res, response(a, "status", "fname", "sname"))
+		}
+		return res, exists
+	})
+}
+
+// similar returns a query of accounts similar to a random one, optionally
+// in the same country or city. Some queries are for unknown accounts.
+func (g *QueryGenerator) similar(
+	name string,
+	find func(id int64, limit int, pp []oracle.Predicate) ([]map[string]interface{}, bool),
+) *Query {
+	a := g.aa[g.r.Intn(len(g.aa))]
+	id := a.ID
+	if g.r.Intn(20) == 0 {
+		id = g.aa[len(g.aa)-1].ID + 1 + g.r.Int63n(1000)
+	}
+
+	limit := 1 + g.r.Intn(20)
+	values := url.Values{}
+	values.Set("limit", strconv.Itoa(limit))
+
+	pp := []oracle.Predicate{}
+	switch g.r.Intn(3) {
+	case 1:
+		c := g.value(a.Country, a.Sex, country)
+		values.Set("country", c)
+		pp = append(pp, oracle.Eq("country", c))
+	case 2:
+		c := g.value(a.City, a.Sex, city)
+		values.Set("city", c)
+		pp = append(pp, oracle.Eq("city", c))
+	}
+
+	tag := fmt.Sprintf("GET:/accounts/<id>/%s/", name)
+	path := fmt.Sprintf("/accounts/%d/%s/", id, name)
+
+	res, exists := find(id, limit, pp)
+	if !exists {
+		q := g.query(tag, path, values, nil)
+		q.StatusCode = http.StatusNotFound
+		return q
+	}
+	return g.query(tag, path, values, map[string]interface{}{
+		"accounts": res,
+	})
+}
+
+// params returns n random distinct parameters with values from a random account,
+// predicates they stand for and account fields to return.
+func (g *QueryGenerator) params(params []*param, n int) (url.Values, []oracle.Predicate, []string) {
+	a := g.aa[g.r.Intn(len(g.aa))]
+
+	values := url.Values{}
+	pp := []oracle.Predicate{}
+	fields := []string{}
+	for _, i := range g.r.Perm(len(params))[:n] {
+		p := params[i]
+		value, predicate := p.make(g, a)
+		values.Set(p.name, value)
+		pp = append(pp, predicate)
+		if p.field != "" {
+			fields = append(fields, p.field)
+		}
+	}
+	return values, pp, fields
+}
+
+func (g *QueryGenerator) query(tag string, path string, values url.Values, response interface{}) *Query {
+	g.id++
+	values.Set("query_id", strconv.Itoa(g.id))
+	return &Query{
+		Tag:        tag,
+		Method:     http.MethodGet,
+		URI:        path + "?" + values.Encode(),
+		StatusCode: http.StatusOK,
+		Response:   response,
+	}
+}
+
+func (g *QueryGenerator) pick(ss []string) string {
+	return ss[g.r.Intn(len(ss))]
+}
+
+func (g *QueryGenerator) null() string {
+	return strconv.Itoa(g.r.Intn(2))
+}
+
+// value returns v if it is not empty or a random vocabulary value otherwise.
+func (g *QueryGenerator) value(v string, sex string, random func(*QueryGenerator, string) string) string {
+	if v != "" {
+		return v
+	}
+	return random(g, sex)
+}
+
+func fname(g *QueryGenerator, sex string) string {
+	if sex == "f" {
+		return g.pick(femaleNames)
+	}
+	return g.pick(maleNames)
+}
+
+func sname(g *QueryGenerator, sex string) string {
+	ends := maleSurnameEnds
+	if sex == "f" {
+		ends = femaleSurnameEnds
+	}
+	return g.pick(surnamePrefixes) + g.pick(surnameMiddles) + g.pick(ends)
+}
+
+func country(g *QueryGenerator, sex string) string {
+	return g.pick(countryPrefixes) + g.pick(countryEnds)
+}
+
+func city(g *QueryGenerator, sex string) string {
+	return g.pick(cityPrefixes) + g.pick(cityEnds)
+}
+
+// interests returns one or two interests of an account or random ones.
+func (g *QueryGenerator) interests(a *accounts.Account) string {
+	ii := a.Interests
+	if len(ii) == 0 {
+		ii = interests
+	}
+	res := []string{ii[g.r.Intn(len(ii))]}
+	if second := ii[g.r.Intn(len(ii))]; second != res[0] && g.r.Intn(2) == 0 {
+		res = append(res, second)
+	}
+	return strings.Join(res, ",")
+}
+
+// likes returns one or two ids liked by an account or a random id.
+func (g *QueryGenerator) likes(a *accounts.Account) string {
+	if len(a.Likes) == 0 {
+		return strconv.FormatInt(g.aa[g.r.Intn(len(g.aa))].ID, 10)
+	}
+	ll := []string{strconv.FormatInt(a.Likes[g.r.Intn(len(a.Likes))].ID, 10)}
+	if second := strconv.FormatInt(a.Likes[g.r.Intn(len(a.Likes))].ID, 10); second != ll[0] && g.r.Intn(2) == 0 {
+		ll = append(ll, second)
+	}
+	return strings.Join(ll, ",")
+}
+
+// response returns id, email and non empty fields of an account.
+func response(a *accounts.Account, fields ...string) map[string]interface{} {
+	res := map[string]interface{}{
+		"id":    a.ID,
+		"email": a.Email,
+	}
+	for _, field := range fields {
+		switch field {
+		case "birth":
+			res[field] = a.Birth
+		case "premium":
+			if a.Premium != nil {
+				res[field] = a.Premium
+			}
+		default:
+			if v := oracle.Field(a, field); v != "" {
+				res[field] = v
+			}
+		}
+	}
+	return res
+}
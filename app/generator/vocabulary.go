@@ -0,0 +1,73 @@
+package generator
+
+// Vocabularies are taken from the contest data, names of countries, cities
+// and surnames are built from syllables the same way.
+var (
+	maleNames = []string{
+		"Александр", "Алексей", "Анатолий", "Андрей", "Антон", "Аркадий", "Артур", "Артём",
+		"Борис", "Борислав", "Вадим", "Василий", "Виктор", "Виталий", "Владимир", "Владислав",
+		"Всеволод", "Вячеслав", "Геннадий", "Георгий", "Глеб", "Григорий", "Дамир", "Даниил",
+		"Данила", "Денис", "Дмитрий", "Евгений", "Егор", "Иван", "Игорь", "Илья", "Кирилл",
+		"Константин", "Леонид", "Макар", "Максим", "Мирослав", "Михаил", "Никита", "Николай",
+		"Олег", "Павел", "Пётр", "Роман", "Руслан", "Савва", "Святослав", "Семён", "Сергей",
+		"Сидор", "Степан", "Филипп", "Фёдор", "Юрий", "Яков", "Ярослав",
+	}
+	femaleNames = []string{
+		"Алина", "Алла", "Алёна", "Анастасия", "Анжелика", "Анна", "Арина", "Валерия",
+		"Василиса", "Вера", "Виктория", "Влада", "Дарина", "Дария", "Дина", "Евгения",
+		"Екатерина", "Елена", "Елизавета", "Злата", "Инесса", "Инна", "Ирина", "Карина",
+		"Кира", "Ксения", "Лариса", "Лера", "Лидия", "Любовь", "Людмила", "Маргарита",
+		"Марина", "Мария", "Милана", "Милена", "Мирра", "Надежда", "Наталья", "Нелли", "Нина",
+		"Оксана", "Олеся", "Ольга", "Полина", "Светлана", "Софья", "Татьяна", "Ульяна",
+		"Юлия", "Яна",
+	}
+
+	surnamePrefixes = []string{
+		"Дан", "Кис", "Клер", "Кол", "Леб", "Лук", "Пен", "Стам", "Стык", "Тер", "Фет", "Хоп", "Шиш",
+	}
+	surnameMiddles    = []string{"ато", "аше", "ета", "лен", "оло", "ушу", "ыка"}
+	maleSurnameEnds   = []string{"вен", "вич", "кий", "ко", "лан", "ло", "ный", "пов", "сян", "тев", "тин", "чан"}
+	femaleSurnameEnds = []string{"вич", "ина", "кая", "ко", "лова", "ная", "сян", "тева", "тина", "чан"}
+
+	countryPrefixes = []string{"Ал", "Гер", "Гол", "Инд", "Исп", "Мал", "Рос", "Рум", "Тур", "Фин"}
+	countryEnds     = []string{"ания", "атрис", "езия", "изия", "ляндия", "маль", "ция"}
+
+	cityPrefixes = []string{
+		"Амстер", "Барс", "Бел", "Вар", "Вол", "Зелен", "Красн", "Крон", "Лейп", "Лес",
+		"Лисс", "Мос", "Мур", "Нов", "Рос", "Роттер", "Санкт", "Светл",
+	}
+	cityEnds = []string{
+		"анск", "атск", "елона", "инск", "обирск", "обон", "обск", "обург", "ово", "огама",
+		"огород", "огорск", "оград", "одам", "одорф", "окамск", "оква", "оламск", "олесск",
+		"олёв", "оморск", "омск", "ополь", "оречск", "ориж", "осинки", "остан", "оштадт", "ярск",
+	}
+
+	interests = []string{
+		"50 Cent", "AC/DC", "Facebook", "PS3", "Pitbull", "South Park", "YouTube", "Аватар",
+		"Автомобили", "Апельсиновый сок", "Баскетбол", "Бег", "Боевые искусства", "Бокс",
+		"Бургеры", "Вечер с друзьями", "Вкусно поесть", "Выходные", "Гарри Поттер",
+		"Гимнастика", "Горы", "Девушки", "Друзья", "Друзья и Близкие", "Еда и Напитки",
+		"Жизнь", "Здоровье", "Знакомство", "Интернет", "Итальянская кухня", "Кинокифильмы",
+		"Клубника", "Компьютеры", "Кофе", "Красное вино", "Курица", "Лето", "Любовь",
+		"Люди Икс", "Массаж", "Матрица", "Металлика", "Мороженое", "Мотоспорт", "Мясо",
+		"На открытом воздухе", "Новые места", "Обнимашки", "Общение", "Овощи", "Паста",
+		"Пиво", "Плавание", "Пляжный отдых", "Поп рок", "Поцелуи", "Приготовление еды",
+		"Прогулки по пляжу", "Путешествия", "Регги", "Романтика", "Рубашки", "Рыба", "Рэп",
+		"Салаты", "Симпсоны", "Солнце", "Сон", "Спагетти", "Спортивные машины", "Стейк",
+		"Танцевальная", "Татуировки", "Текила", "Телевидение", "Титаник", "Туфли",
+		"Тяжёлая атлетика", "Ужин с друзьями", "Фильмы", "Фитнес", "Форсаж", "Фотография",
+		"Фрукты", "Хип Хоп", "Целоваться", "Честность", "Чудак", "Шопинг", "Юмор",
+	}
+
+	emailDomains = []string{
+		"me.com", "yandex.ru", "email.com", "gmail.com", "ya.ru", "ymail.com", "inbox.ru",
+		"mail.ru", "rambler.ru", "inbox.com", "yahoo.com", "icloud.com", "list.ru",
+	}
+	emailSyllables = []string{
+		"ah", "ar", "bi", "ce", "da", "de", "ed", "en", "fe", "ge", "he", "hu", "it", "le",
+		"mo", "ne", "ni", "ol", "or", "ra", "re", "se", "ta", "te", "ti", "to", "tu", "wo",
+	}
+
+	// premiumDays are possible premium periods in days.
+	premiumDays = []int64{30, 91, 182, 365}
+)
@@ -1,8 +1,15 @@
 package importer
 
-import "io"
+import (
+	"io"
+	"time"
+)
 
 // Importer imports data.
 type Importer interface {
 	Read() ([]io.Reader, error)
+	// ModTime returns time when the data was last modified.
+	ModTime() (time.Time, error)
+	// Size returns a size of the data in bytes.
+	Size() (int64, error)
 }
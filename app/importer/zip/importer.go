@@ -6,7 +6,9 @@ import (
 	"fmt"
 	"io"
 	"io/ioutil"
+	"os"
 	"path/filepath"
+	"time"
 
 	"github.com/ngalayko/highloadcup/app/logger"
 )
@@ -57,3 +59,55 @@ func (z *Importer) Read() ([]io.Reader, error) {
 	}
 	return result, nil
 }
+
+// optionsFile is a name of a file with the current timestamp and a dataset
+// type, in the archive or next to it.
+const optionsFile = "options.txt"
+
+// Options returns options.txt from zip archive or next to it, or nil if
+// there are no options.
+func (z *Importer) Options() ([]byte, error) {
+	rc, err := zip.OpenReader(z.path)
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+
+	for _, f := range rc.File {
+		if f.Name != optionsFile {
+			continue
+		}
+
+		frc, err := f.Open()
+		if err != nil {
+			return nil, fmt.Errorf("can't open file '%s': %s", f.Name, err)
+		}
+		defer frc.Close()
+
+		return ioutil.ReadAll(frc)
+	}
+
+	options, err := ioutil.ReadFile(filepath.Join(filepath.Dir(z.path), optionsFile))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	return options, err
+}
+
+// ModTime returns zip archive modification time.
+func (z *Importer) ModTime() (time.Time, error) {
+	info, err := os.Stat(z.path)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return info.ModTime(), nil
+}
+
+// Size returns zip archive size.
+func (z *Importer) Size() (int64, error) {
+	info, err := os.Stat(z.path)
+	if err != nil {
+		return 0, err
+	}
+	return info.Size(), nil
+}
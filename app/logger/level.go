@@ -0,0 +1,42 @@
+package logger
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Level is a log level.
+type Level int32
+
+// Log levels from the most to the least verbose.
+const (
+	DebugLevel Level = iota
+	InfoLevel
+	ErrorLevel
+	// OffLevel disables all logs except panics.
+	OffLevel
+)
+
+var levelNames = map[Level]string{
+	DebugLevel: "debug",
+	InfoLevel:  "info",
+	ErrorLevel: "error",
+	OffLevel:   "off",
+}
+
+func (l Level) String() string {
+	if name, exists := levelNames[l]; exists {
+		return name
+	}
+	return fmt.Sprintf("level(%d)", int32(l))
+}
+
+// ParseLevel returns a level by name.
+func ParseLevel(name string) (Level, error) {
+	for l, n := range levelNames {
+		if n == strings.ToLower(name) {
+			return l, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown log level %q", name)
+}
@@ -1,28 +1,275 @@
 package logger
 
 import (
+	"fmt"
+	"io"
 	"log"
+	"strconv"
+	"sync/atomic"
+	"time"
 )
 
-// Logger is a logger object.
-type Logger struct{}
+// Field is a structured log key/value pair.
+type Field struct {
+	Key   string
+	Value interface{}
+}
+
+// F returns a log field.
+func F(key string, value interface{}) Field {
+	return Field{
+		Key:   key,
+		Value: value,
+	}
+}
+
+// config is shared by a logger and all loggers derived from it with With.
+type config struct {
+	level int32
+	// sample is a request log sampling rate: every sample-th request is
+	// logged, 0 disables request logs.
+	sample   uint64
+	requests uint64
+
+	out io.Writer
+	// stdTime is true when entries are written to the standard logger,
+	// which adds its own timestamp.
+	stdTime   bool
+	asyncSize int
+	async     *asyncWriter
+}
+
+// Logger is a logger object, the zero value logs to the standard logger
+// at info level.
+type Logger struct {
+	c      *config
+	fields []Field
+}
+
+// Option is a logger option.
+type Option func(*config)
+
+// WithLevel sets the minimal level of logged entries.
+func WithLevel(level Level) Option {
+	return func(c *config) {
+		c.level = int32(level)
+	}
+}
+
+// WithOutput sets the log output, the standard logger is used by default.
+func WithOutput(out io.Writer) Option {
+	return func(c *config) {
+		c.out = out
+		c.stdTime = false
+	}
+}
+
+// WithAsync writes entries from a background goroutine buffering up to size
+// entries, entries are dropped when the buffer is full.
+func WithAsync(size int) Option {
+	return func(c *config) {
+		c.asyncSize = size
+	}
+}
+
+// WithRequestSampling logs every rate-th request, 0 disables request logs.
+// Request logs are disabled by default.
+func WithRequestSampling(rate uint64) Option {
+	return func(c *config) {
+		c.sample = rate
+	}
+}
+
+func defaultConfig() *config {
+	return &config{
+		level:   int32(InfoLevel),
+		out:     stdWriter{},
+		stdTime: true,
+	}
+}
+
+// zeroConfig is used by zero value loggers.
+var zeroConfig = defaultConfig()
 
 // New is a logger constructor.
-func New() *Logger {
-	return &Logger{}
+func New(opts ...Option) *Logger {
+	c := defaultConfig()
+	for _, opt := range opts {
+		opt(c)
+	}
+	if c.asyncSize > 0 {
+		c.async = newAsyncWriter(c.out, c.asyncSize)
+	}
+	return &Logger{
+		c: c,
+	}
+}
+
+func (l *Logger) config() *config {
+	if l.c == nil {
+		return zeroConfig
+	}
+	return l.c
+}
+
+// With returns a logger adding fields to every entry.
+func (l *Logger) With(fields ...Field) *Logger {
+	ff := make([]Field, 0, len(l.fields)+len(fields))
+	ff = append(ff, l.fields...)
+	ff = append(ff, fields...)
+	return &Logger{
+		c:      l.c,
+		fields: ff,
+	}
+}
+
+// Level returns the current log level.
+func (l *Logger) Level() Level {
+	return Level(atomic.LoadInt32(&l.config().level))
+}
+
+// SetLevel changes the log level of the logger and all derived loggers.
+func (l *Logger) SetLevel(level Level) {
+	atomic.StoreInt32(&l.config().level, int32(level))
+}
+
+// Enabled returns true if entries of a level are logged.
+func (l *Logger) Enabled(level Level) bool {
+	return level >= l.Level()
+}
+
+// RequestSampling returns the current request log sampling rate.
+func (l *Logger) RequestSampling() uint64 {
+	return atomic.LoadUint64(&l.config().sample)
+}
+
+// SetRequestSampling changes the request log sampling rate,
+// 0 disables request logs.
+func (l *Logger) SetRequestSampling(rate uint64) {
+	atomic.StoreUint64(&l.config().sample, rate)
+}
+
+// SampleRequest returns true if the current request should be logged.
+func (l *Logger) SampleRequest() bool {
+	rate := l.RequestSampling()
+	if rate == 0 || !l.Enabled(InfoLevel) {
+		return false
+	}
+	return atomic.AddUint64(&l.config().requests, 1)%rate == 0
+}
+
+// Dropped returns the number of entries dropped by the async writer.
+func (l *Logger) Dropped() uint64 {
+	async := l.config().async
+	if async == nil {
+		return 0
+	}
+	return atomic.LoadUint64(&async.dropped)
+}
+
+// Flush waits until all buffered entries are written.
+func (l *Logger) Flush() {
+	if async := l.config().async; async != nil {
+		async.Flush()
+	}
+}
+
+// Close writes all buffered entries and stops the async writer,
+// entries logged after close are written synchronously.
+func (l *Logger) Close() {
+	if async := l.config().async; async != nil {
+		async.Close()
+	}
+}
+
+// Debug prints debug log.
+func (l *Logger) Debug(format string, args ...interface{}) {
+	if l.Enabled(DebugLevel) {
+		l.print("[DEBUG] ", format, args)
+	}
 }
 
 // Info prints information log.
 func (l *Logger) Info(format string, args ...interface{}) {
-	log.Printf("[INFO] "+format+"\n", args...)
+	if l.Enabled(InfoLevel) {
+		l.print("[INFO] ", format, args)
+	}
 }
 
 // Error prints error log.
 func (l *Logger) Error(format string, args ...interface{}) {
-	log.Printf("[ERROR] "+format+"\n", args...)
+	if l.Enabled(ErrorLevel) {
+		l.print("[ERROR] ", format, args)
+	}
 }
 
 // Panic prints panic log and thrown a panic.
 func (l *Logger) Panic(format string, args ...interface{}) {
-	log.Panicf("[PANIC] "+format+"\n", args...)
+	msg := fmt.Sprintf(format, args...)
+	l.print("[PANIC] ", "%s", []interface{}{msg})
+	l.Flush()
+	log.Panic(msg)
+}
+
+// print writes an entry formatted as `[TAG] message key=value`, prefixed
+// with a timestamp unless the standard logger adds one.
+func (l *Logger) print(tag string, format string, args []interface{}) {
+	c := l.config()
+
+	b := *getBuffer()
+	if !c.stdTime {
+		b = time.Now().AppendFormat(b, "2006-01-02T15:04:05.000000Z07:00 ")
+	}
+	b = append(b, tag...)
+	b = append(b, fmt.Sprintf(format, args...)...)
+	for _, f := range l.fields {
+		b = append(b, ' ')
+		b = append(b, f.Key...)
+		b = append(b, '=')
+		b = appendValue(b, f.Value)
+	}
+	b = append(b, '\n')
+	buf := &b
+
+	if c.async != nil {
+		c.async.add(buf)
+		return
+	}
+	c.out.Write(*buf)
+	putBuffer(buf)
+}
+
+// appendValue appends a field value, quoting strings with spaces.
+func appendValue(b []byte, v interface{}) []byte {
+	switch v := v.(type) {
+	case string:
+		return appendString(b, v)
+	case []byte:
+		return appendString(b, string(v))
+	case int:
+		return strconv.AppendInt(b, int64(v), 10)
+	case int64:
+		return strconv.AppendInt(b, v, 10)
+	case uint64:
+		return strconv.AppendUint(b, v, 10)
+	case bool:
+		return strconv.AppendBool(b, v)
+	case time.Duration:
+		return append(b, v.String()...)
+	case error:
+		return appendString(b, v.Error())
+	case fmt.Stringer:
+		return appendString(b, v.String())
+	default:
+		return appendString(b, fmt.Sprint(v))
+	}
+}
+
+func appendString(b []byte, s string) []byte {
+	for i := 0; i < len(s); i++ {
+		if s[i] <= ' ' || s[i] == '"' || s[i] == '=' {
+			return strconv.AppendQuote(b, s)
+		}
+	}
+	return append(b, s...)
 }
@@ -0,0 +1,135 @@
+package logger
+
+import (
+	"bytes"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// syncBuffer is a buffer safe for concurrent use.
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) lines() []string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return strings.Split(strings.TrimSpace(b.buf.String()), "\n")
+}
+
+func TestLevels(t *testing.T) {
+	out := &syncBuffer{}
+	l := New(WithOutput(out), WithLevel(InfoLevel))
+
+	l.Debug("debug")
+	l.Info("info %d", 1)
+	l.SetLevel(ErrorLevel)
+	l.Info("info %d", 2)
+	l.Error("error")
+	l.SetLevel(DebugLevel)
+	l.Debug("debug")
+
+	lines := out.lines()
+	expected := []string{"[INFO] info 1", "[ERROR] error", "[DEBUG] debug"}
+	if len(lines) != len(expected) {
+		t.Fatalf("expected %d lines, got %q", len(expected), lines)
+	}
+	for i := range expected {
+		if !strings.HasSuffix(lines[i], expected[i]) {
+			t.Fatalf("expected %q, got %q", expected[i], lines[i])
+		}
+	}
+}
+
+func TestFields(t *testing.T) {
+	out := &syncBuffer{}
+	l := New(WithOutput(out)).With(F("a", 1), F("b", "x y"))
+
+	l.With(F("c", time.Second), F("d", []byte("z"))).Info("msg")
+	l.Info("msg")
+
+	lines := out.lines()
+	for i, expected := range []string{
+		`[INFO] msg a=1 b="x y" c=1s d=z`,
+		`[INFO] msg a=1 b="x y"`,
+	} {
+		if !strings.HasSuffix(lines[i], expected) {
+			t.Fatalf("expected %q, got %q", expected, lines[i])
+		}
+	}
+}
+
+func TestParseLevel(t *testing.T) {
+	for _, level := range []Level{DebugLevel, InfoLevel, ErrorLevel, OffLevel} {
+		parsed, err := ParseLevel(strings.ToUpper(level.String()))
+		if err != nil || parsed != level {
+			t.Fatalf("%s: got %s, %v", level, parsed, err)
+		}
+	}
+	if _, err := ParseLevel("warn"); err == nil {
+		t.Fatal("expected an error for unknown level")
+	}
+}
+
+func TestSampleRequest(t *testing.T) {
+	l := New(WithOutput(&syncBuffer{}), WithRequestSampling(3))
+
+	sampled := 0
+	for i := 0; i < 30; i++ {
+		if l.SampleRequest() {
+			sampled++
+		}
+	}
+	if sampled != 10 {
+		t.Fatalf("expected 10 sampled requests, got %d", sampled)
+	}
+
+	l.SetRequestSampling(0)
+	if l.SampleRequest() {
+		t.Fatal("expected request logs to be disabled")
+	}
+
+	l.SetRequestSampling(1)
+	l.SetLevel(ErrorLevel)
+	if l.SampleRequest() {
+		t.Fatal("expected request logs to be disabled by level")
+	}
+}
+
+func TestAsync(t *testing.T) {
+	out := &syncBuffer{}
+	l := New(WithOutput(out), WithAsync(1000))
+
+	wg := &sync.WaitGroup{}
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				l.Info("entry %d", j)
+			}
+		}()
+	}
+	wg.Wait()
+	l.Flush()
+
+	if lines := out.lines(); uint64(len(lines))+l.Dropped() != 500 {
+		t.Fatalf("expected 500 written or dropped entries, got %d and %d dropped", len(lines), l.Dropped())
+	}
+
+	l.Close()
+	l.Info("after close")
+	lines := out.lines()
+	if !strings.HasSuffix(lines[len(lines)-1], "[INFO] after close") {
+		t.Fatalf("expected an entry after close, got %q", lines[len(lines)-1])
+	}
+}
@@ -0,0 +1,137 @@
+package logger
+
+import (
+	"io"
+	"log"
+	"sync"
+	"sync/atomic"
+)
+
+// stdWriter writes to the standard logger.
+type stdWriter struct{}
+
+func (stdWriter) Write(p []byte) (int, error) {
+	return len(p), log.Output(2, string(p))
+}
+
+// asyncWriter writes entries to out from a background goroutine.
+// Entries are dropped instead of blocking a caller when the buffer is full.
+type asyncWriter struct {
+	out     io.Writer
+	entries chan *[]byte
+	flush   chan chan struct{}
+	done    chan struct{}
+	dropped uint64
+
+	// mu guards entries from sends after close.
+	mu     sync.RWMutex
+	closed bool
+}
+
+func newAsyncWriter(out io.Writer, size int) *asyncWriter {
+	w := &asyncWriter{
+		out:     out,
+		entries: make(chan *[]byte, size),
+		flush:   make(chan chan struct{}),
+		done:    make(chan struct{}),
+	}
+	go w.loop()
+	return w
+}
+
+func (w *asyncWriter) loop() {
+	defer close(w.done)
+	for {
+		select {
+		case buf, ok := <-w.entries:
+			if !ok {
+				return
+			}
+			w.write(buf)
+		case flushed := <-w.flush:
+			w.drain()
+			close(flushed)
+		}
+	}
+}
+
+// drain writes all buffered entries.
+func (w *asyncWriter) drain() {
+	for {
+		select {
+		case buf, ok := <-w.entries:
+			if !ok {
+				return
+			}
+			w.write(buf)
+		default:
+			return
+		}
+	}
+}
+
+func (w *asyncWriter) write(buf *[]byte) {
+	w.out.Write(*buf)
+	putBuffer(buf)
+}
+
+// add queues an entry, the writer owns buf afterwards.
+// Entries added after close are written synchronously.
+func (w *asyncWriter) add(buf *[]byte) {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+
+	if w.closed {
+		w.write(buf)
+		return
+	}
+
+	select {
+	case w.entries <- buf:
+	default:
+		atomic.AddUint64(&w.dropped, 1)
+		putBuffer(buf)
+	}
+}
+
+// Flush waits until all queued entries are written.
+func (w *asyncWriter) Flush() {
+	flushed := make(chan struct{})
+	select {
+	case w.flush <- flushed:
+		<-flushed
+	case <-w.done:
+	}
+}
+
+// Close writes all queued entries and stops the writer.
+func (w *asyncWriter) Close() {
+	w.mu.Lock()
+	if !w.closed {
+		w.closed = true
+		close(w.entries)
+	}
+	w.mu.Unlock()
+
+	<-w.done
+}
+
+var buffers = sync.Pool{
+	New: func() interface{} {
+		buf := make([]byte, 0, 256)
+		return &buf
+	},
+}
+
+func getBuffer() *[]byte {
+	buf := buffers.Get().(*[]byte)
+	*buf = (*buf)[:0]
+	return buf
+}
+
+func putBuffer(buf *[]byte) {
+	if cap(*buf) > 64<<10 {
+		return
+	}
+	buffers.Put(buf)
+}
@@ -0,0 +1,361 @@
+// Package metrics collects metrics and writes them in Prometheus text
+// exposition format.
+package metrics
+
+import (
+	"bufio"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// DefaultBuckets are latency histogram buckets in seconds.
+var DefaultBuckets = []float64{
+	0.0001, 0.00025, 0.0005, 0.001, 0.0025, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1,
+}
+
+// metric is a metric family.
+type metric interface {
+	write(w *bufio.Writer)
+}
+
+// Registry holds metrics in registration order.
+type Registry struct {
+	mu      sync.Mutex
+	metrics []metric
+}
+
+// New is a registry constructor.
+func New() *Registry {
+	return &Registry{}
+}
+
+func (r *Registry) register(m metric) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.metrics = append(r.metrics, m)
+}
+
+// Write writes all metrics in text exposition format.
+func (r *Registry) Write(w io.Writer) error {
+	r.mu.Lock()
+	mm := r.metrics
+	r.mu.Unlock()
+
+	bw := bufio.NewWriter(w)
+	for _, m := range mm {
+		m.write(bw)
+	}
+	return bw.Flush()
+}
+
+// Counter is a monotonically increasing value.
+type Counter struct {
+	value uint64
+}
+
+// Inc increments a counter.
+func (c *Counter) Inc() {
+	atomic.AddUint64(&c.value, 1)
+}
+
+// Add adds n to a counter.
+func (c *Counter) Add(n uint64) {
+	atomic.AddUint64(&c.value, n)
+}
+
+// Value returns a counter value.
+func (c *Counter) Value() uint64 {
+	return atomic.LoadUint64(&c.value)
+}
+
+// Histogram counts observations in buckets.
+type Histogram struct {
+	buckets []float64
+	counts  []uint64
+	count   uint64
+	// sum holds float64 bits.
+	sum uint64
+}
+
+func newHistogram(buckets []float64) *Histogram {
+	return &Histogram{
+		buckets: buckets,
+		counts:  make([]uint64, len(buckets)),
+	}
+}
+
+// Observe adds a value to a histogram.
+func (h *Histogram) Observe(v float64) {
+	if i := sort.SearchFloat64s(h.buckets, v); i < len(h.buckets) {
+		atomic.AddUint64(&h.counts[i], 1)
+	}
+	atomic.AddUint64(&h.count, 1)
+	for {
+		old := atomic.LoadUint64(&h.sum)
+		sum := math.Float64bits(math.Float64frombits(old) + v)
+		if atomic.CompareAndSwapUint64(&h.sum, old, sum) {
+			return
+		}
+	}
+}
+
+// vec holds metrics by label values.
+type vec struct {
+	name   string
+	help   string
+	typ    string
+	labels []string
+
+	mu     sync.RWMutex
+	values map[string][]string
+	items  map[string]interface{}
+	new    func() interface{}
+}
+
+func newVec(name, help, typ string, labels []string, new func() interface{}) *vec {
+	return &vec{
+		name:   name,
+		help:   help,
+		typ:    typ,
+		labels: labels,
+		values: map[string][]string{},
+		items:  map[string]interface{}{},
+		new:    new,
+	}
+}
+
+// with returns a metric with label values, creating it if needed.
+func (v *vec) with(values []string) interface{} {
+	if len(values) != len(v.labels) {
+		panic("metrics: " + v.name + ": expected " + strconv.Itoa(len(v.labels)) + " label values")
+	}
+	key := strings.Join(values, "\xff")
+
+	v.mu.RLock()
+	item, exists := v.items[key]
+	v.mu.RUnlock()
+	if exists {
+		return item
+	}
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	if item, exists := v.items[key]; exists {
+		return item
+	}
+	item = v.new()
+	v.items[key] = item
+	v.values[key] = append([]string{}, values...)
+	return item
+}
+
+// each calls f with label pairs and metrics sorted by label values.
+func (v *vec) each(f func(labels []string, item interface{})) {
+	v.mu.RLock()
+	keys := make([]string, 0, len(v.items))
+	for key := range v.items {
+		keys = append(keys, key)
+	}
+	v.mu.RUnlock()
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		v.mu.RLock()
+		values, item := v.values[key], v.items[key]
+		v.mu.RUnlock()
+
+		labels := make([]string, 0, 2*len(values))
+		for i, value := range values {
+			labels = append(labels, v.labels[i], value)
+		}
+		f(labels, item)
+	}
+}
+
+// CounterVec is a counter partitioned by labels.
+type CounterVec struct {
+	*vec
+}
+
+// Counter registers a counter with labels.
+func (r *Registry) Counter(name, help string, labels ...string) *CounterVec {
+	c := &CounterVec{
+		vec: newVec(name, help, "counter", labels, func() interface{} {
+			return &Counter{}
+		}),
+	}
+	r.register(c)
+	return c
+}
+
+// With returns a counter with label values.
+func (c *CounterVec) With(values ...string) *Counter {
+	return c.with(values).(*Counter)
+}
+
+func (c *CounterVec) write(w *bufio.Writer) {
+	writeHeader(w, c.name, c.help, c.typ)
+	c.each(func(labels []string, item interface{}) {
+		writeSample(w, c.name, labels, float64(item.(*Counter).Value()))
+	})
+}
+
+// HistogramVec is a histogram partitioned by labels.
+type HistogramVec struct {
+	*vec
+	buckets []float64
+}
+
+// Histogram registers a histogram with sorted upper bounds of buckets
+// and labels.
+func (r *Registry) Histogram(name, help string, buckets []float64, labels ...string) *HistogramVec {
+	h := &HistogramVec{
+		vec: newVec(name, help, "histogram", labels, func() interface{} {
+			return newHistogram(buckets)
+		}),
+		buckets: buckets,
+	}
+	r.register(h)
+	return h
+}
+
+// With returns a histogram with label values.
+func (h *HistogramVec) With(values ...string) *Histogram {
+	return h.with(values).(*Histogram)
+}
+
+func (h *HistogramVec) write(w *bufio.Writer) {
+	writeHeader(w, h.name, h.help, h.typ)
+	h.each(func(labels []string, item interface{}) {
+		hist := item.(*Histogram)
+		count := atomic.LoadUint64(&hist.count)
+
+		cumulative := uint64(0)
+		for i, le := range h.buckets {
+			cumulative += atomic.LoadUint64(&hist.counts[i])
+			writeSample(w, h.name+"_bucket", append(labels, "le", formatFloat(le)), float64(cumulative))
+		}
+		writeSample(w, h.name+"_bucket", append(labels, "le", "+Inf"), float64(count))
+		writeSample(w, h.name+"_sum", labels, math.Float64frombits(atomic.LoadUint64(&hist.sum)))
+		writeSample(w, h.name+"_count", labels, float64(count))
+	})
+}
+
+// funcMetric is a metric with values collected on write.
+type funcMetric struct {
+	name  string
+	help  string
+	typ   string
+	label string
+	f     func() map[string]float64
+}
+
+// GaugeFunc registers a gauge with a value returned by f.
+func (r *Registry) GaugeFunc(name, help string, f func() float64) {
+	r.register(&funcMetric{
+		name: name,
+		help: help,
+		typ:  "gauge",
+		f: func() map[string]float64 {
+			return map[string]float64{"": f()}
+		},
+	})
+}
+
+// CounterFunc registers a counter with a value returned by f.
+func (r *Registry) CounterFunc(name, help string, f func() float64) {
+	r.register(&funcMetric{
+		name: name,
+		help: help,
+		typ:  "counter",
+		f: func() map[string]float64 {
+			return map[string]float64{"": f()}
+		},
+	})
+}
+
+// GaugeVecFunc registers a gauge with values by a label returned by f.
+func (r *Registry) GaugeVecFunc(name, help, label string, f func() map[string]float64) {
+	r.register(&funcMetric{
+		name:  name,
+		help:  help,
+		typ:   "gauge",
+		label: label,
+		f:     f,
+	})
+}
+
+func (m *funcMetric) write(w *bufio.Writer) {
+	writeHeader(w, m.name, m.help, m.typ)
+
+	values := m.f()
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		var labels []string
+		if m.label != "" {
+			labels = []string{m.label, key}
+		}
+		writeSample(w, m.name, labels, values[key])
+	}
+}
+
+var helpReplacer = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+
+func writeHeader(w *bufio.Writer, name, help, typ string) {
+	w.WriteString("# HELP ")
+	w.WriteString(name)
+	w.WriteByte(' ')
+	w.WriteString(helpReplacer.Replace(help))
+	w.WriteString("\n# TYPE ")
+	w.WriteString(name)
+	w.WriteByte(' ')
+	w.WriteString(typ)
+	w.WriteByte('\n')
+}
+
+var labelReplacer = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// writeSample writes a sample line, labels are name and value pairs.
+func writeSample(w *bufio.Writer, name string, labels []string, value float64) {
+	w.WriteString(name)
+	if len(labels) > 0 {
+		w.WriteByte('{')
+		for i := 0; i < len(labels); i += 2 {
+			if i > 0 {
+				w.WriteByte(',')
+			}
+			w.WriteString(labels[i])
+			w.WriteString(`="`)
+			w.WriteString(labelReplacer.Replace(labels[i+1]))
+			w.WriteByte('"')
+		}
+		w.WriteByte('}')
+	}
+	w.WriteByte(' ')
+	w.WriteString(formatFloat(value))
+	w.WriteByte('\n')
+}
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
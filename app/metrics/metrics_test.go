@@ -0,0 +1,83 @@
+package metrics
+
+import (
+	"bytes"
+	"sync"
+	"testing"
+)
+
+func TestWrite(t *testing.T) {
+	r := New()
+	requests := r.Counter("requests_total", "Number of requests.", "endpoint", "status")
+	duration := r.Histogram("duration_seconds", "Request latency.", []float64{0.1, 1}, "endpoint")
+	r.GaugeFunc("accounts", "Number of accounts.", func() float64 {
+		return 3
+	})
+	r.GaugeVecFunc("buckets", "Number of keys by index.", "index", func() map[string]float64 {
+		return map[string]float64{"byCity": 2, "bySex": 1}
+	})
+
+	requests.With("group", "400").Inc()
+	requests.With("filter", "200").Add(2)
+	requests.With("filter", "200").Inc()
+	duration.With("filter").Observe(0.05)
+	duration.With("filter").Observe(0.1)
+	duration.With("filter").Observe(0.5)
+	duration.With("filter").Observe(2)
+	requests.With(`a"b`, "200").Inc()
+
+	buf := &bytes.Buffer{}
+	if err := r.Write(buf); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `# HELP requests_total Number of requests.
+# TYPE requests_total counter
+requests_total{endpoint="a\"b",status="200"} 1
+requests_total{endpoint="filter",status="200"} 3
+requests_total{endpoint="group",status="400"} 1
+# HELP duration_seconds Request latency.
+# TYPE duration_seconds histogram
+duration_seconds_bucket{endpoint="filter",le="0.1"} 2
+duration_seconds_bucket{endpoint="filter",le="1"} 3
+duration_seconds_bucket{endpoint="filter",le="+Inf"} 4
+duration_seconds_sum{endpoint="filter"} 2.65
+duration_seconds_count{endpoint="filter"} 4
+# HELP accounts Number of accounts.
+# TYPE accounts gauge
+accounts 3
+# HELP buckets Number of keys by index.
+# TYPE buckets gauge
+buckets{index="byCity"} 2
+buckets{index="bySex"} 1
+`
+	if buf.String() != expected {
+		t.Fatalf("expected:\n%s\ngot:\n%s", expected, buf.String())
+	}
+}
+
+func TestConcurrent(t *testing.T) {
+	r := New()
+	requests := r.Counter("requests_total", "Number of requests.", "endpoint")
+	duration := r.Histogram("duration_seconds", "Request latency.", DefaultBuckets, "endpoint")
+
+	wg := &sync.WaitGroup{}
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 1000; j++ {
+				requests.With("filter").Inc()
+				duration.With("filter").Observe(0.001)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if v := requests.With("filter").Value(); v != 8000 {
+		t.Fatalf("expected 8000 requests, got %d", v)
+	}
+	if sum := duration.With("filter").sum; sum == 0 {
+		t.Fatal("expected non-zero duration sum")
+	}
+}
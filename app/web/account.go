@@ -0,0 +1,37 @@
+package web
+
+import (
+	"bytes"
+	"strconv"
+
+	"github.com/valyala/fasthttp"
+)
+
+var (
+	accountsPrefix = []byte("/accounts/")
+	pathSuffix     = []byte("/")
+)
+
+// accountID returns account id from /accounts/<id>/ path.
+func accountID(path []byte) (int64, bool) {
+	if !bytes.HasPrefix(path, accountsPrefix) || !bytes.HasSuffix(path, pathSuffix) {
+		return 0, false
+	}
+	id, err := strconv.ParseInt(string(path[len(accountsPrefix):len(path)-len(pathSuffix)]), 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	return id, true
+}
+
+func (w *Web) account(id int64) func(*fasthttp.RequestCtx) {
+	return func(ctx *fasthttp.RequestCtx) {
+		a, exists := w.datastore.Account(id)
+		if !exists {
+			ctx.SetStatusCode(fasthttp.StatusNotFound)
+			return
+		}
+
+		w.responseJSON(ctx, a)
+	}
+}
@@ -0,0 +1,89 @@
+package web
+
+import (
+	"encoding/json"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/valyala/fasthttp"
+
+	"github.com/ngalayko/highloadcup/app/accounts"
+	"github.com/ngalayko/highloadcup/app/datastore"
+	"github.com/ngalayko/highloadcup/app/logger"
+)
+
+const accountData = `{"accounts": [
+	{"id": 1, "email": "one@mail.ru", "fname": "Анна", "sex": "f", "birth": 631152000,
+		"joined": 1325376000, "status": "свободны", "interests": ["Пиво", "Футбол"],
+		"premium": {"start": 1514764800, "finish": 1546300800},
+		"likes": [{"id": 2, "ts": 1500000000}]},
+	{"id": 2, "email": "two@gmail.com", "sex": "m", "birth": 662688000,
+		"joined": 1356998400, "status": "заняты"}
+]}`
+
+type stringImporter string
+
+func (i stringImporter) Read() ([]io.Reader, error) {
+	return []io.Reader{strings.NewReader(string(i))}, nil
+}
+
+func (i stringImporter) ModTime() (time.Time, error) {
+	return time.Time{}, nil
+}
+
+func (i stringImporter) Size() (int64, error) {
+	return int64(len(i)), nil
+}
+
+func getAccount(t *testing.T, w *Web, path string) (int, *accounts.Account) {
+	ctx := &fasthttp.RequestCtx{}
+	ctx.Request.Header.SetMethod("GET")
+	ctx.Request.SetRequestURI(path)
+	w.handler(ctx)
+
+	if ctx.Response.StatusCode() != fasthttp.StatusOK {
+		return ctx.Response.StatusCode(), nil
+	}
+	a := &accounts.Account{}
+	if err := json.Unmarshal(ctx.Response.Body(), a); err != nil {
+		t.Fatalf("%s: %s", path, err)
+	}
+	return ctx.Response.StatusCode(), a
+}
+
+func TestAccount(t *testing.T) {
+	d, err := datastore.New(logger.New(), stringImporter(accountData))
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := New(logger.New(), d)
+
+	status, a := getAccount(t, w, "/accounts/1/?query_id=1")
+	if status != fasthttp.StatusOK {
+		t.Fatalf("expected 200, got %d", status)
+	}
+	expected := &accounts.Account{
+		ID:        1,
+		Email:     "one@mail.ru",
+		FName:     "Анна",
+		Sex:       "f",
+		Birth:     631152000,
+		Joined:    1325376000,
+		Status:    "свободны",
+		Interests: []string{"Пиво", "Футбол"},
+		Premium:   &accounts.Premium{Start: 1514764800, Finish: 1546300800},
+		Likes:     []*accounts.Like{{ID: 2, Timestamp: 1500000000}},
+	}
+	if !reflect.DeepEqual(a, expected) {
+		t.Fatalf("expected %+v, got %+v", expected, a)
+	}
+
+	for _, path := range []string{"/accounts/3/", "/accounts/0/", "/accounts/-1/", "/accounts/1a/", "/accounts/1"} {
+		if status, _ := getAccount(t, w, path); status != fasthttp.StatusNotFound {
+			t.Fatalf("%s: expected 404, got %d", path, status)
+		}
+	}
+}
@@ -0,0 +1,244 @@
+package web
+
+import (
+	"io/ioutil"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/valyala/fasthttp"
+
+	"github.com/ngalayko/highloadcup/app/ammo"
+	"github.com/ngalayko/highloadcup/app/datastore"
+	"github.com/ngalayko/highloadcup/app/importer/zip"
+	"github.com/ngalayko/highloadcup/app/logger"
+)
+
+const benchDataPath = "../../test_data/data/data.zip"
+
+var benchAmmoPaths = []string{
+	"../../test_data/ammo/phase_1_get.ammo",
+	"../../test_data/ammo/phase_3_get.ammo",
+}
+
+var (
+	benchOnce    sync.Once
+	benchWeb     *Web
+	benchQueries map[string][]string
+	benchErr     error
+)
+
+// loadBench loads data.zip and ammo queries once for all benchmarks.
+func loadBench(b *testing.B) (*Web, map[string][]string) {
+	benchOnce.Do(func() {
+		log.SetOutput(ioutil.Discard)
+
+		if _, benchErr = os.Stat(benchDataPath); benchErr != nil {
+			return
+		}
+
+		var d *datastore.Datastore
+		d, benchErr = datastore.New(logger.New(), zip.New(benchDataPath))
+		if benchErr != nil {
+			return
+		}
+		benchWeb = New(logger.New(), d)
+
+		benchQueries = map[string][]string{}
+		for _, path := range benchAmmoPaths {
+			if benchErr = parseAmmo(path, benchQueries); benchErr != nil {
+				return
+			}
+		}
+	})
+	if benchErr != nil {
+		b.Skipf("no benchmark data: %s", benchErr)
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	return benchWeb, benchQueries
+}
+
+// parseAmmo adds request uris from a Yandex.Tank ammo file to queries by tag.
+func parseAmmo(path string, queries map[string][]string) error {
+	rr, err := ammo.Parse(path)
+	if err != nil {
+		return err
+	}
+	for _, r := range rr {
+		queries[r.Tag] = append(queries[r.Tag], r.URI)
+	}
+	return nil
+}
+
+// args returns query args of a request uri.
+func args(uri string) *fasthttp.Args {
+	a := &fasthttp.Args{}
+	if i := strings.IndexByte(uri, '?'); i >= 0 {
+		a.Parse(uri[i+1:])
+	}
+	return a
+}
+
+func BenchmarkHandler(b *testing.B) {
+	w, queries := loadBench(b)
+
+	tags := make([]string, 0, len(queries))
+	for tag := range queries {
+		if tag != "" {
+			tags = append(tags, tag)
+		}
+	}
+	sort.Strings(tags)
+
+	for _, tag := range tags {
+		uris := queries[tag]
+		b.Run(strings.Trim(strings.Replace(tag, "/", "_", -1), "_"), func(b *testing.B) {
+			benchHandler(b, w, tag, uris)
+		})
+	}
+}
+
+// benchHandler runs handler with uris, it skips a benchmark if all of them
+// are not found.
+func benchHandler(b *testing.B, w *Web, tag string, uris []string) {
+	found := false
+	for _, uri := range uris {
+		ctx := &fasthttp.RequestCtx{}
+		ctx.Request.SetRequestURI(uri)
+		w.handler(ctx)
+		if ctx.Response.StatusCode() != fasthttp.StatusNotFound {
+			found = true
+			break
+		}
+	}
+	if !found {
+		b.Skipf("%s is not implemented", tag)
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		ctx := &fasthttp.RequestCtx{}
+		ctx.Request.SetRequestURI(uris[i%len(uris)])
+		w.handler(ctx)
+	}
+}
+
+func BenchmarkRecommend(b *testing.B) {
+	w, queries := loadBench(b)
+	benchHandler(b, w, "GET:/accounts/<id>/recommend/", queries["GET:/accounts/<id>/recommend/"])
+}
+
+func BenchmarkSuggest(b *testing.B) {
+	w, queries := loadBench(b)
+	benchHandler(b, w, "GET:/accounts/<id>/suggest/", queries["GET:/accounts/<id>/suggest/"])
+}
+
+// filterQueries returns valid parsed filter queries.
+func filterQueries(w *Web, uris []string) []*filterQuery {
+	qq := []*filterQuery{}
+	for _, uri := range uris {
+		if q, err := w.parseFilter(args(uri)); err == nil {
+			qq = append(qq, q)
+		}
+	}
+	return qq
+}
+
+// groupQueries returns valid parsed group queries.
+func groupQueries(w *Web, uris []string) []*groupQuery {
+	qq := []*groupQuery{}
+	for _, uri := range uris {
+		if q, err := w.parseGroup(args(uri)); err == nil {
+			qq = append(qq, q)
+		}
+	}
+	return qq
+}
+
+func BenchmarkParseFilter(b *testing.B) {
+	w, queries := loadBench(b)
+	uris := queries["GET:/accounts/filter/"]
+
+	for i := 0; i < b.N; i++ {
+		w.parseFilter(args(uris[i%len(uris)]))
+	}
+}
+
+func BenchmarkFilterAccounts(b *testing.B) {
+	w, queries := loadBench(b)
+	qq := filterQueries(w, queries["GET:/accounts/filter/"])
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		q := qq[i%len(qq)]
+		if _, err := w.datastore.FilterAccounts(q.limit, q.filters...); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkFilterResponse(b *testing.B) {
+	w, queries := loadBench(b)
+	qq := filterQueries(w, queries["GET:/accounts/filter/"])
+	responses := make([]*filterAccounts, 0, len(qq))
+	for _, q := range qq {
+		aa, err := w.datastore.FilterAccounts(q.limit, q.filters...)
+		if err != nil {
+			b.Fatal(err)
+		}
+		responses = append(responses, filterResponse(aa, q))
+	}
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		w.responseJSON(&fasthttp.RequestCtx{}, responses[i%len(responses)])
+	}
+}
+
+func BenchmarkParseGroup(b *testing.B) {
+	w, queries := loadBench(b)
+	uris := queries["GET:/accounts/group/"]
+
+	for i := 0; i < b.N; i++ {
+		w.parseGroup(args(uris[i%len(uris)]))
+	}
+}
+
+func BenchmarkGroupAccounts(b *testing.B) {
+	w, queries := loadBench(b)
+	qq := groupQueries(w, queries["GET:/accounts/group/"])
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		q := qq[i%len(qq)]
+		if _, err := w.datastore.GroupAccounts(q.keys, q.desc, q.limit, q.filters...); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkGroupResponse(b *testing.B) {
+	w, queries := loadBench(b)
+	qq := groupQueries(w, queries["GET:/accounts/group/"])
+	responses := make([]*groupsResponse, 0, len(qq))
+	for _, q := range qq {
+		groups, err := w.datastore.GroupAccounts(q.keys, q.desc, q.limit, q.filters...)
+		if err != nil {
+			b.Fatal(err)
+		}
+		responses = append(responses, &groupsResponse{
+			Groups: groups,
+		})
+	}
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		w.responseJSON(&fasthttp.RequestCtx{}, responses[i%len(responses)])
+	}
+}
@@ -0,0 +1,71 @@
+package web
+
+import (
+	"github.com/ngalayko/highloadcup/app/datastore"
+)
+
+// WithExplain enables explain=1 parameter of filter and group queries,
+// which returns query plans instead of results.
+func WithExplain() Option {
+	return func(w *Web) {
+		w.explain = true
+	}
+}
+
+type explainStep struct {
+	Predicate string `json:"predicate"`
+	// Index is an index candidates are taken from, subsequent filters
+	// narrow candidates of previous steps.
+	Index      string `json:"index"`
+	Estimated  *int   `json:"estimated"`
+	Actual     int    `json:"actual"`
+	DurationNs int64  `json:"duration_ns"`
+}
+
+type explainResponse struct {
+	Predicates []string       `json:"predicates"`
+	Seed       string         `json:"seed"`
+	Steps      []*explainStep `json:"steps"`
+	Groups     *int           `json:"groups,omitempty"`
+	Results    int            `json:"results"`
+	DurationNs int64          `json:"duration_ns"`
+}
+
+// parseExplain parses explain parameter, it is unknown unless enabled.
+func (w *Web) parseExplain(key, value []byte) (bool, error) {
+	if !w.explain {
+		return false, errUnknownParameter(key)
+	}
+	return string(value) == "1", nil
+}
+
+// explainResponse returns a response for a plan of a query which returned
+// results.
+func (w *Web) explainResponse(plan *datastore.Plan, results int) *explainResponse {
+	w.datastore.Estimate(plan)
+
+	res := &explainResponse{
+		Predicates: plan.Predicates,
+		Seed:       plan.Seed,
+		Steps:      make([]*explainStep, 0, len(plan.Steps)),
+		Results:    results,
+		DurationNs: plan.Duration.Nanoseconds(),
+	}
+	for i, s := range plan.Steps {
+		step := &explainStep{
+			Predicate:  s.Predicate,
+			Index:      "candidates",
+			Actual:     s.Candidates,
+			DurationNs: s.Duration.Nanoseconds(),
+		}
+		if i == 0 {
+			step.Index = plan.Seed
+		}
+		if s.Estimated >= 0 {
+			step.Estimated = new(int)
+			*step.Estimated = s.Estimated
+		}
+		res.Steps = append(res.Steps, step)
+	}
+	return res
+}
@@ -0,0 +1,31 @@
+package web
+
+import (
+	"bufio"
+
+	"github.com/valyala/fasthttp"
+
+	"github.com/ngalayko/highloadcup/app/exporter/zip"
+)
+
+// WithExportOptions writes options.txt of the dataset to exported archives.
+func WithExportOptions(options []byte) Option {
+	return func(w *Web) {
+		w.exportOptions = options
+	}
+}
+
+func (w *Web) export() func(*fasthttp.RequestCtx) {
+	return func(ctx *fasthttp.RequestCtx) {
+		aa := w.datastore.Accounts()
+
+		ctx.Response.Header.SetContentType("application/zip")
+		ctx.Response.Header.Set("Content-Disposition", `attachment; filename="data.zip"`)
+		ctx.SetStatusCode(fasthttp.StatusOK)
+		ctx.SetBodyStreamWriter(func(bw *bufio.Writer) {
+			if err := zip.New(bw, zip.WithOptions(w.exportOptions)).Write(aa); err != nil {
+				w.log.Error("export: %s", err)
+			}
+		})
+	}
+}
@@ -2,6 +2,7 @@ package web
 
 import (
 	"errors"
+	"fmt"
 	"sort"
 	"strconv"
 
@@ -11,302 +12,388 @@ import (
 	"github.com/ngalayko/highloadcup/app/datastore"
 )
 
-var errLimitNotSpecified = errors.New("limit not specified")
+var (
+	errLimitNotSpecified = errors.New("limit not specified")
+	errInvalidLimit      = errors.New("limit must be a positive number")
+)
 
-func (w *Web) accountsFilter() func(ctx *fasthttp.RequestCtx) {
+// filterAccount is an account in a filter response, only fields used
+// in filters are returned.
+type filterAccount struct {
+	ID        int64             `json:"id"`
+	Email     string            `json:"email"`
+	Sex       *string           `json:"sex,omitempty"`
+	Status    *string           `json:"status,omitempty"`
+	FName     *string           `json:"fname,omitempty"`
+	SName     *string           `json:"sname,omitempty"`
+	Phone     *string           `json:"phone,omitempty"`
+	Country   *string           `json:"country,omitempty"`
+	City      *string           `json:"city,omitempty"`
+	Birth     *int64            `json:"birth,omitempty"`
+	Interests []string          `json:"interests,omitempty"`
+	Likes     []string          `json:"likes,omitempty"`
+	Premium   *accounts.Premium `json:"premium,omitempty"`
+}
 
-	type Account struct {
-		ID        int64             `json:"id"`
-		Email     string            `json:"email"`
-		Sex       *string           `json:"sex,omitempty"`
-		Status    *string           `json:"status,omitempty"`
-		FName     *string           `json:"fname,omitempty"`
-		SName     *string           `json:"sname,omitempty"`
-		Phone     *string           `json:"phome,omitempty"`
-		Country   *string           `json:"country,omitempty"`
-		City      *string           `json:"city,omitempty"`
-		Birth     *int64            `json:"birth,omitempty"`
-		Interests []string          `json:"interests,omitempty"`
-		Likes     []string          `json:"likes,omitempty"`
-		Premium   *accounts.Premium `json:"premium,omitempty"`
-	}
+type filterAccounts struct {
+	Accounts []*filterAccount `json:"accounts"`
+}
 
-	type Accounts struct {
-		Accounts []*Account `json:"accounts"`
-	}
+// filterQuery is a parsed filter query.
+type filterQuery struct {
+	limit   int
+	filters []datastore.FilterFunc
+	// predicates name filters as key=value parameters.
+	predicates []string
+	// args are account fields used in filters.
+	args map[string]bool
+	// explain returns a query plan instead of results.
+	explain bool
+}
 
+func (w *Web) accountsFilter() func(ctx *fasthttp.RequestCtx) {
 	return func(ctx *fasthttp.RequestCtx) {
-		filters := make([]datastore.FilterFunc, 0, ctx.URI().QueryArgs().Len())
-		args := make(map[string]bool, ctx.URI().QueryArgs().Len())
-		var parseErr error
-		var limit int
-		ctx.URI().QueryArgs().VisitAll(func(key, value []byte) {
-			switch string(key) {
-			case "limit":
-				limit, parseErr = strconv.Atoi(string(value))
-				args["limit"] = true
-			case "sex_eq":
-				filters = append(filters, w.datastore.FilterSex(datastore.Equal(string(value))))
-				args["sex"] = true
-			case "email_domain":
-				filters = append(
-					filters,
-					w.datastore.FilterEmail(datastore.Domain(string(value))),
-				)
-				args["email"] = true
-			case "email_lt":
-				filters = append(
-					filters,
-					w.datastore.FilterEmail(datastore.Lt(string(value))),
-				)
-				args["email"] = true
-			case "email_gt":
-				filters = append(
-					filters,
-					w.datastore.FilterEmail(datastore.Gt(string(value))),
-				)
-				args["email"] = true
-			case "status_eq":
-				filters = append(
-					filters,
-					w.datastore.FilterStatus(datastore.Equal(string(value))),
-				)
-				args["status"] = true
-			case "status_neq":
-				filters = append(
-					filters,
-					w.datastore.FilterStatus(datastore.NotEqual(string(value))),
-				)
-				args["status"] = true
-			case "fname_eq":
-				filters = append(
-					filters,
-					w.datastore.FilterFName(datastore.Equal(string(value))),
-				)
-				args["fname"] = true
-			case "fname_any":
-				filters = append(
-					filters,
-					w.datastore.FilterFName(datastore.Any(string(value))),
-				)
-				args["fname"] = true
-			case "fname_null":
-				filters = append(
-					filters,
-					w.datastore.FilterFName(datastore.Null(string(value))),
-				)
-				args["fname"] = true
-			case "sname_eq":
-				filters = append(
-					filters,
-					w.datastore.FilterSName(datastore.Equal(string(value))),
-				)
-				args["sname"] = true
-			case "sname_starts":
-				filters = append(
-					filters,
-					w.datastore.FilterSName(datastore.Starts(string(value))),
-				)
-				args["sname"] = true
-			case "sname_null":
-				filters = append(
-					filters,
-					w.datastore.FilterSName(datastore.Null(string(value))),
-				)
-				args["sname"] = true
-			case "phone_code":
-				filters = append(
-					filters,
-					w.datastore.FilterPhone(datastore.Code(string(value))),
-				)
-				args["phone"] = true
-			case "phone_null":
-				filters = append(
-					filters,
-					w.datastore.FilterPhone(datastore.Null(string(value))),
-				)
-				args["phone"] = true
-			case "country_eq":
-				filters = append(
-					filters,
-					w.datastore.FilterCountry(datastore.Equal(string(value))),
-				)
-				args["country"] = true
-			case "country_null":
-				filters = append(
-					filters,
-					w.datastore.FilterCountry(datastore.Null(string(value))),
-				)
-				args["country"] = true
-			case "city_eq":
-				filters = append(
-					filters,
-					w.datastore.FilterCity(datastore.Equal(string(value))),
-				)
-				args["city"] = true
-			case "city_any":
-				filters = append(
-					filters,
-					w.datastore.FilterCity(datastore.Any(string(value))),
-				)
-				args["city"] = true
-			case "city_null":
-				filters = append(
-					filters,
-					w.datastore.FilterCity(datastore.Null(string(value))),
-				)
-				args["city"] = true
-			case "birth_lt":
-				filter, err := datastore.Before(string(value))
-				if err != nil {
-					parseErr = err
-					return
-				}
-				filters = append(
-					filters,
-					w.datastore.FilterBirth(filter),
-				)
-				args["birth"] = true
-			case "birth_gt":
-				filter, err := datastore.After(string(value))
-				if err != nil {
-					parseErr = err
-					return
-				}
-				filters = append(
-					filters,
-					w.datastore.FilterBirth(filter),
-				)
-				args["birth"] = true
-			case "birth_year":
-				filter, err := datastore.Year(string(value))
-				if err != nil {
-					parseErr = err
-					return
-				}
-				filters = append(
-					filters,
-					w.datastore.FilterBirth(filter),
-				)
-				args["birth"] = true
-			case "interests_contains":
-				filters = append(
-					filters,
-					w.datastore.FilterInterestsContains(value),
-				)
-				args["interests"] = true
-			case "interests_any":
-				filters = append(
-					filters,
-					w.datastore.FilterInterestsAny(value),
-				)
-				args["interests"] = true
-			case "likes_contains":
-				filters = append(
-					filters,
-					w.datastore.FilterLikesContains(value),
-				)
-				args["likes"] = true
-			case "premium_now":
-				filter, err := w.datastore.FilterPremiumNow(string(value))
-				if err != nil {
-					parseErr = err
-					return
-				}
-				filters = append(filters, filter)
-				args["premium"] = true
-			case "premium_null":
-				filters = append(
-					filters,
-					w.datastore.FilterPremiumNull(string(value)),
-				)
-				args["premium"] = true
-			default:
-				return
-			}
-		})
-
-		if parseErr != nil {
-			w.error(ctx, parseErr)
-			return
-		}
-
-		if !args["limit"] {
-			w.error(ctx, errLimitNotSpecified)
+		q, err := w.parseFilter(ctx.URI().QueryArgs())
+		if err != nil {
+			w.error(ctx, err)
 			return
 		}
 
-		res := &Accounts{
-			Accounts: make([]*Account, 0, limit),
+		plan := w.plan(q.predicates)
+		if q.explain {
+			plan = datastore.NewPlan(q.predicates...)
 		}
-
-		aa, err := w.datastore.FilterAccounts(limit, filters...)
+		aa, err := w.datastore.FilterAccountsPlan(plan, q.limit, q.filters...)
 		if err != nil {
 			w.error(ctx, err)
 			return
 		}
+		w.logSlow(ctx, plan)
 
-		for _, a := range aa {
-			ac := &Account{
-				ID:    a.ID,
-				Email: a.Email,
+		if q.explain {
+			results := len(aa)
+			if results > q.limit {
+				results = q.limit
 			}
+			w.responseJSON(ctx, w.explainResponse(plan, results))
+			return
+		}
+		w.responseJSON(ctx, filterResponse(aa, q))
+	}
+}
 
-			if args["sex"] {
-				ac.Sex = new(string)
-				*ac.Sex = a.Sex
+// parseFilter parses filter query arguments.
+func (w *Web) parseFilter(query *fasthttp.Args) (*filterQuery, error) {
+	filters := make([]datastore.FilterFunc, 0, query.Len())
+	predicates := make([]string, 0, query.Len())
+	args := make(map[string]bool, query.Len())
+	var parseErr error
+	var limit int
+	var explain bool
+	query.VisitAll(func(key, value []byte) {
+		defer func(n int) {
+			if len(filters) > n {
+				predicates = append(predicates, string(key)+"="+string(value))
 			}
+		}(len(filters))
 
-			if args["status"] {
-				ac.Status = new(string)
-				*ac.Status = a.Status
+		switch string(key) {
+		case "limit":
+			l, err := parseLimit(value)
+			if err != nil {
+				parseErr = err
+				return
 			}
-
-			if args["fname"] && a.FName != "" {
-				ac.FName = new(string)
-				*ac.FName = a.FName
+			limit = l
+			args["limit"] = true
+		case "sex_eq":
+			filters = append(filters, w.datastore.FilterSex(datastore.Equal(string(value))))
+			args["sex"] = true
+		case "email_domain":
+			filters = append(
+				filters,
+				w.datastore.FilterEmail(datastore.Domain(string(value))),
+			)
+			args["email"] = true
+		case "email_lt":
+			filters = append(
+				filters,
+				w.datastore.FilterEmail(datastore.Lt(string(value))),
+			)
+			args["email"] = true
+		case "email_gt":
+			filters = append(
+				filters,
+				w.datastore.FilterEmail(datastore.Gt(string(value))),
+			)
+			args["email"] = true
+		case "status_eq":
+			filters = append(
+				filters,
+				w.datastore.FilterStatus(datastore.Equal(string(value))),
+			)
+			args["status"] = true
+		case "status_neq":
+			filters = append(
+				filters,
+				w.datastore.FilterStatus(datastore.NotEqual(string(value))),
+			)
+			args["status"] = true
+		case "fname_eq":
+			filters = append(
+				filters,
+				w.datastore.FilterFName(datastore.Equal(string(value))),
+			)
+			args["fname"] = true
+		case "fname_any":
+			filters = append(
+				filters,
+				w.datastore.FilterFName(datastore.Any(string(value))),
+			)
+			args["fname"] = true
+		case "fname_null":
+			filters = append(
+				filters,
+				w.datastore.FilterFName(datastore.Null(string(value))),
+			)
+			args["fname"] = true
+		case "sname_eq":
+			filters = append(
+				filters,
+				w.datastore.FilterSName(datastore.Equal(string(value))),
+			)
+			args["sname"] = true
+		case "sname_starts":
+			filters = append(
+				filters,
+				w.datastore.FilterSName(datastore.Starts(string(value))),
+			)
+			args["sname"] = true
+		case "sname_null":
+			filters = append(
+				filters,
+				w.datastore.FilterSName(datastore.Null(string(value))),
+			)
+			args["sname"] = true
+		case "phone_code":
+			filters = append(
+				filters,
+				w.datastore.FilterPhone(datastore.Code(string(value))),
+			)
+			args["phone"] = true
+		case "phone_null":
+			filters = append(
+				filters,
+				w.datastore.FilterPhone(datastore.Null(string(value))),
+			)
+			args["phone"] = true
+		case "country_eq":
+			filters = append(
+				filters,
+				w.datastore.FilterCountry(datastore.Equal(string(value))),
+			)
+			args["country"] = true
+		case "country_null":
+			filters = append(
+				filters,
+				w.datastore.FilterCountry(datastore.Null(string(value))),
+			)
+			args["country"] = true
+		case "city_eq":
+			filters = append(
+				filters,
+				w.datastore.FilterCity(datastore.Equal(string(value))),
+			)
+			args["city"] = true
+		case "city_any":
+			filters = append(
+				filters,
+				w.datastore.FilterCity(datastore.Any(string(value))),
+			)
+			args["city"] = true
+		case "city_null":
+			filters = append(
+				filters,
+				w.datastore.FilterCity(datastore.Null(string(value))),
+			)
+			args["city"] = true
+		case "birth_lt":
+			filter, err := datastore.Before(string(value))
+			if err != nil {
+				parseErr = err
+				return
 			}
-
-			if args["sname"] && a.SName != "" {
-				ac.SName = new(string)
-				*ac.SName = a.SName
+			filters = append(
+				filters,
+				w.datastore.FilterBirth(filter),
+			)
+			args["birth"] = true
+		case "birth_gt":
+			filter, err := datastore.After(string(value))
+			if err != nil {
+				parseErr = err
+				return
 			}
-
-			if args["phone"] && a.Phone != "" {
-				ac.Phone = new(string)
-				*ac.Phone = a.Phone
+			filters = append(
+				filters,
+				w.datastore.FilterBirth(filter),
+			)
+			args["birth"] = true
+		case "birth_year":
+			filter, err := datastore.Year(string(value))
+			if err != nil {
+				parseErr = err
+				return
 			}
-
-			if args["country"] && a.Country != "" {
-				ac.Country = new(string)
-				*ac.Country = a.Country
+			filters = append(
+				filters,
+				w.datastore.FilterBirth(filter),
+			)
+			args["birth"] = true
+		case "interests_contains":
+			filters = append(
+				filters,
+				w.datastore.FilterInterestsContains(value),
+			)
+			args["interests"] = true
+		case "interests_any":
+			filters = append(
+				filters,
+				w.datastore.FilterInterestsAny(value),
+			)
+			args["interests"] = true
+		case "likes_contains":
+			filters = append(
+				filters,
+				w.datastore.FilterLikesContains(value),
+			)
+			args["likes"] = true
+		case "premium_now":
+			filter, err := w.datastore.FilterPremiumNow(string(value))
+			if err != nil {
+				parseErr = err
+				return
 			}
-
-			if args["city"] && a.City != "" {
-				ac.City = new(string)
-				*ac.City = a.City
+			filters = append(filters, filter)
+			args["premium"] = true
+		case "premium_null":
+			filters = append(
+				filters,
+				w.datastore.FilterPremiumNull(string(value)),
+			)
+			args["premium"] = true
+		case "explain":
+			e, err := w.parseExplain(key, value)
+			if err != nil {
+				parseErr = err
+				return
 			}
+			explain = e
+		case "query_id":
+		default:
+			parseErr = errUnknownParameter(key)
+		}
+	})
 
-			if args["birth"] {
-				ac.Birth = new(int64)
-				*ac.Birth = a.Birth
-			}
+	if parseErr != nil {
+		return nil, parseErr
+	}
 
-			if args["premium"] {
-				ac.Premium = a.Premium
-			}
+	if !args["limit"] {
+		return nil, errLimitNotSpecified
+	}
+
+	return &filterQuery{
+		limit:      limit,
+		filters:    filters,
+		predicates: predicates,
+		args:       args,
+		explain:    explain,
+	}, nil
+}
+
+// filterResponse returns at most limit filtered accounts ordered by id descending.
+func filterResponse(aa map[int64]*accounts.Account, q *filterQuery) *filterAccounts {
+	limit := q.limit
+	args := q.args
+
+	res := &filterAccounts{
+		Accounts: make([]*filterAccount, 0, limit),
+	}
 
-			res.Accounts = append(res.Accounts, ac)
+	for _, a := range aa {
+		ac := &filterAccount{
+			ID:    a.ID,
+			Email: a.Email,
 		}
 
-		sort.Slice(res.Accounts, func(i, j int) bool {
-			return res.Accounts[i].ID > res.Accounts[j].ID
-		})
+		if args["sex"] {
+			ac.Sex = new(string)
+			*ac.Sex = a.Sex
+		}
+
+		if args["status"] {
+			ac.Status = new(string)
+			*ac.Status = a.Status
+		}
+
+		if args["fname"] && a.FName != "" {
+			ac.FName = new(string)
+			*ac.FName = a.FName
+		}
+
+		if args["sname"] && a.SName != "" {
+			ac.SName = new(string)
+			*ac.SName = a.SName
+		}
 
-		if len(res.Accounts) > int(limit) {
-			res.Accounts = res.Accounts[:limit]
+		if args["phone"] && a.Phone != "" {
+			ac.Phone = new(string)
+			*ac.Phone = a.Phone
 		}
 
-		w.responseJSON(ctx, res)
+		if args["country"] && a.Country != "" {
+			ac.Country = new(string)
+			*ac.Country = a.Country
+		}
+
+		if args["city"] && a.City != "" {
+			ac.City = new(string)
+			*ac.City = a.City
+		}
+
+		if args["birth"] {
+			ac.Birth = new(int64)
+			*ac.Birth = a.Birth
+		}
+
+		if args["premium"] {
+			ac.Premium = a.Premium
+		}
+
+		res.Accounts = append(res.Accounts, ac)
+	}
+
+	sort.Slice(res.Accounts, func(i, j int) bool {
+		return res.Accounts[i].ID > res.Accounts[j].ID
+	})
+
+	if len(res.Accounts) > int(limit) {
+		res.Accounts = res.Accounts[:limit]
+	}
+
+	return res
+}
+
+func errUnknownParameter(key []byte) error {
+	return fmt.Errorf("unknown parameter %s", key)
+}
+
+// parseLimit parses a positive limit.
+func parseLimit(value []byte) (int, error) {
+	limit, err := strconv.Atoi(string(value))
+	if err != nil || limit <= 0 {
+		return 0, errInvalidLimit
 	}
+	return limit, nil
 }
@@ -3,125 +3,214 @@ package web
 import (
 	"bytes"
 	"errors"
-	"strconv"
+	"fmt"
 
 	"github.com/ngalayko/highloadcup/app/datastore"
 	"github.com/valyala/fasthttp"
 )
 
-var orderNitSpecified = errors.New("order not specified")
+var (
+	orderNitSpecified   = errors.New("order not specified")
+	errInvalidOrder     = errors.New("order must be 1 or -1")
+	errKeysNotSpecified = errors.New("keys not specified")
+)
 
-func (w *Web) accountsGroup() func(ctx *fasthttp.RequestCtx) {
+type groupsResponse struct {
+	Groups []map[string]interface{} `json:"groups"`
+}
 
-	type Groups struct {
-		Groups []map[string]interface{} `json:"groups"`
-	}
+// groupQuery is a parsed group query.
+type groupQuery struct {
+	keys    []datastore.GroupKeyFunc
+	desc    bool
+	limit   int
+	filters []datastore.FilterFunc
+	// predicates name filters as key=value parameters.
+	predicates []string
+	// explain returns a query plan instead of results.
+	explain bool
+}
 
+func (w *Web) accountsGroup() func(ctx *fasthttp.RequestCtx) {
 	return func(ctx *fasthttp.RequestCtx) {
-		var order *bool
-		var limit int
-		var parseErr error
-		filters := make([]datastore.FilterFunc, 0, ctx.URI().QueryArgs().Len())
-		var groups []datastore.GroupKeyFunc
-		ctx.URI().QueryArgs().VisitAll(func(key, value []byte) {
-			switch string(key) {
-			case "keys":
-				kk := bytes.Split(value, []byte{','})
-				groups = make([]datastore.GroupKeyFunc, 0, len(kk))
-				for _, k := range kk {
-					switch string(k) {
-					case "sex":
-						groups = append(groups, datastore.GroupSex())
-					case "status":
-						groups = append(groups, datastore.GroupStatus())
-					case "interests":
-						groups = append(groups, datastore.GroupInterests())
-					case "country":
-						groups = append(groups, datastore.GroupCountry())
-					case "city":
-						groups = append(groups, datastore.GroupCity())
-					default:
-						continue
-					}
-				}
-			case "order":
-				order = new(bool)
-				*order = bytes.HasPrefix(value, []byte{'-'})
-			case "limit":
-				limit, parseErr = strconv.Atoi(string(value))
-			case "birth":
-				filter, err := datastore.Year(string(value))
-				if err != nil {
-					parseErr = err
-					return
-				}
-				filters = append(
-					filters,
-					w.datastore.FilterBirth(filter),
-				)
-			case "fname":
-				filters = append(
-					filters,
-					w.datastore.FilterFName(datastore.Equal(string(value))),
-				)
-			case "sname":
-				filters = append(
-					filters,
-					w.datastore.FilterSName(datastore.Equal(string(value))),
-				)
-			case "city":
-				filters = append(
-					filters,
-					w.datastore.FilterCity(datastore.Equal(string(value))),
-				)
-			case "joined":
-				filter, err := datastore.Year(string(value))
-				if err != nil {
-					parseErr = err
-					return
-				}
-				filters = append(
-					filters,
-					w.datastore.FilterJoined(filter),
-				)
-			case "status":
-				filters = append(
-					filters,
-					w.datastore.FilterStatus(datastore.Equal(string(value))),
-				)
-			case "likes":
-				filters = append(
-					filters,
-					w.datastore.FilterLikesContains(value),
-				)
-			case "interests":
-				filters = append(
-					filters,
-					w.datastore.FilterInterestsContains(value),
-				)
-			default:
-				return
-			}
-		})
-
-		if parseErr != nil {
-			w.error(ctx, parseErr)
+		q, err := w.parseGroup(ctx.URI().QueryArgs())
+		if err != nil {
+			w.error(ctx, err)
 			return
 		}
 
-		if order == nil {
-			w.error(ctx, orderNitSpecified)
-			return
+		plan := w.plan(q.predicates)
+		if q.explain {
+			plan = datastore.NewPlan(q.predicates...)
 		}
-
-		respGroups, err := w.datastore.GroupAccounts(groups, *order, limit, filters...)
+		groups, err := w.datastore.GroupAccountsPlan(plan, q.keys, q.desc, q.limit, q.filters...)
 		if err != nil {
 			w.error(ctx, err)
 			return
 		}
+		w.logSlow(ctx, plan)
 
-		w.responseJSON(ctx, &Groups{
-			Groups: respGroups,
+		if q.explain {
+			res := w.explainResponse(plan, len(groups))
+			res.Groups = &plan.Groups
+			w.responseJSON(ctx, res)
+			return
+		}
+
+		w.responseJSON(ctx, &groupsResponse{
+			Groups: groups,
 		})
 	}
 }
+
+// parseGroup parses group query arguments.
+func (w *Web) parseGroup(query *fasthttp.Args) (*groupQuery, error) {
+	var order *bool
+	var limit int
+	var parseErr error
+	var explain bool
+	filters := make([]datastore.FilterFunc, 0, query.Len())
+	predicates := make([]string, 0, query.Len())
+	var groups []datastore.GroupKeyFunc
+	query.VisitAll(func(key, value []byte) {
+		defer func(n int) {
+			if len(filters) > n {
+				predicates = append(predicates, string(key)+"="+string(value))
+			}
+		}(len(filters))
+
+		switch string(key) {
+		case "keys":
+			kk := bytes.Split(value, []byte{','})
+			groups = make([]datastore.GroupKeyFunc, 0, len(kk))
+			for _, k := range kk {
+				switch string(k) {
+				case "sex":
+					groups = append(groups, datastore.GroupSex())
+				case "status":
+					groups = append(groups, datastore.GroupStatus())
+				case "interests":
+					groups = append(groups, datastore.GroupInterests())
+				case "country":
+					groups = append(groups, datastore.GroupCountry())
+				case "city":
+					groups = append(groups, datastore.GroupCity())
+				default:
+					parseErr = fmt.Errorf("unknown group key %s", k)
+				}
+			}
+		case "order":
+			switch string(value) {
+			case "1":
+				order = new(bool)
+			case "-1":
+				order = new(bool)
+				*order = true
+			default:
+				parseErr = errInvalidOrder
+			}
+		case "limit":
+			l, err := parseLimit(value)
+			if err != nil {
+				parseErr = err
+				return
+			}
+			limit = l
+		case "sex":
+			filters = append(
+				filters,
+				w.datastore.FilterSex(datastore.Equal(string(value))),
+			)
+		case "country":
+			filters = append(
+				filters,
+				w.datastore.FilterCountry(datastore.Equal(string(value))),
+			)
+		case "birth":
+			filter, err := datastore.Year(string(value))
+			if err != nil {
+				parseErr = err
+				return
+			}
+			filters = append(
+				filters,
+				w.datastore.FilterBirth(filter),
+			)
+		case "fname":
+			filters = append(
+				filters,
+				w.datastore.FilterFName(datastore.Equal(string(value))),
+			)
+		case "sname":
+			filters = append(
+				filters,
+				w.datastore.FilterSName(datastore.Equal(string(value))),
+			)
+		case "city":
+			filters = append(
+				filters,
+				w.datastore.FilterCity(datastore.Equal(string(value))),
+			)
+		case "joined":
+			filter, err := datastore.Year(string(value))
+			if err != nil {
+				parseErr = err
+				return
+			}
+			filters = append(
+				filters,
+				w.datastore.FilterJoined(filter),
+			)
+		case "status":
+			filters = append(
+				filters,
+				w.datastore.FilterStatus(datastore.Equal(string(value))),
+			)
+		case "likes":
+			filters = append(
+				filters,
+				w.datastore.FilterLikesContains(value),
+			)
+		case "interests":
+			filters = append(
+				filters,
+				w.datastore.FilterInterestsContains(value),
+			)
+		case "explain":
+			e, err := w.parseExplain(key, value)
+			if err != nil {
+				parseErr = err
+				return
+			}
+			explain = e
+		case "query_id":
+		default:
+			parseErr = errUnknownParameter(key)
+		}
+	})
+
+	if parseErr != nil {
+		return nil, parseErr
+	}
+
+	if order == nil {
+		return nil, orderNitSpecified
+	}
+
+	if groups == nil {
+		return nil, errKeysNotSpecified
+	}
+
+	if limit == 0 {
+		return nil, errLimitNotSpecified
+	}
+
+	return &groupQuery{
+		keys:       groups,
+		desc:       *order,
+		limit:      limit,
+		filters:    filters,
+		predicates: predicates,
+		explain:    explain,
+	}, nil
+}
@@ -1,9 +1,81 @@
 package web
 
-import "github.com/valyala/fasthttp"
+import (
+	"sync/atomic"
 
-func (w *Web) healthcheck() func(*fasthttp.RequestCtx) {
+	"github.com/valyala/fasthttp"
+
+	"github.com/ngalayko/highloadcup/app/datastore"
+)
+
+// WithProgress reports datastore loading progress on /healthcheck/ready.
+func WithProgress(p *datastore.Progress) Option {
+	return func(w *Web) {
+		w.progress = p
+	}
+}
+
+// SetDatastore sets a loaded datastore and makes the server ready.
+func (w *Web) SetDatastore(d *datastore.Datastore) {
+	w.datastore = d
+	atomic.StoreInt32(&w.ready, 1)
+}
+
+// isReady returns true if the datastore is loaded.
+func (w *Web) isReady() bool {
+	return atomic.LoadInt32(&w.ready) == 1
+}
+
+// servedLoading returns true if a path is served while the datastore
+// is loading.
+func servedLoading(path []byte) bool {
+	switch string(path) {
+	case "/healthcheck", "/healthcheck/live", "/healthcheck/ready":
+		return true
+	}
+	return false
+}
+
+type readinessResponse struct {
+	Ready      bool   `json:"ready"`
+	Stage      string `json:"stage"`
+	Files      int    `json:"files"`
+	TotalFiles int    `json:"total_files"`
+	Accounts   int    `json:"accounts"`
+	Error      string `json:"error,omitempty"`
+}
+
+// live responds OK while the server is running.
+func (w *Web) live() func(*fasthttp.RequestCtx) {
 	return func(ctx *fasthttp.RequestCtx) {
 		ctx.SetStatusCode(fasthttp.StatusOK)
 	}
 }
+
+// readiness responds with loading progress, the status is OK only
+// when the datastore is loaded.
+func (w *Web) readiness() func(*fasthttp.RequestCtx) {
+	return func(ctx *fasthttp.RequestCtx) {
+		res := &readinessResponse{
+			Ready: w.isReady(),
+			Stage: datastore.StageReady,
+		}
+		if w.progress != nil {
+			state := w.progress.State()
+			res.Stage = state.Stage
+			res.Files = state.Files
+			res.TotalFiles = state.TotalFiles
+			res.Accounts = state.Accounts
+			if state.Err != nil {
+				res.Error = state.Err.Error()
+			}
+		} else if res.Ready {
+			res.Accounts = w.datastore.Stats().Accounts
+		}
+
+		w.responseJSON(ctx, res)
+		if !res.Ready {
+			ctx.SetStatusCode(fasthttp.StatusServiceUnavailable)
+		}
+	}
+}
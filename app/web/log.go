@@ -0,0 +1,57 @@
+package web
+
+import (
+	"strconv"
+
+	"github.com/valyala/fasthttp"
+
+	"github.com/ngalayko/highloadcup/app/logger"
+)
+
+type logConfigResponse struct {
+	Level           string `json:"level"`
+	RequestSampling uint64 `json:"request_sampling"`
+	Dropped         uint64 `json:"dropped"`
+}
+
+func (w *Web) logConfig() func(*fasthttp.RequestCtx) {
+	return func(ctx *fasthttp.RequestCtx) {
+		w.responseJSON(ctx, &logConfigResponse{
+			Level:           w.log.Level().String(),
+			RequestSampling: w.log.RequestSampling(),
+			Dropped:         w.log.Dropped(),
+		})
+	}
+}
+
+// setLogConfig changes the log level and request log sampling rate
+// from level and request_sampling parameters.
+func (w *Web) setLogConfig() func(*fasthttp.RequestCtx) {
+	return func(ctx *fasthttp.RequestCtx) {
+		args := ctx.URI().QueryArgs()
+
+		level := w.log.Level()
+		if value := args.Peek("level"); value != nil {
+			l, err := logger.ParseLevel(string(value))
+			if err != nil {
+				w.error(ctx, err)
+				return
+			}
+			level = l
+		}
+
+		sampling := w.log.RequestSampling()
+		if value := args.Peek("request_sampling"); value != nil {
+			rate, err := strconv.ParseUint(string(value), 10, 64)
+			if err != nil {
+				w.error(ctx, err)
+				return
+			}
+			sampling = rate
+		}
+
+		w.log.SetLevel(level)
+		w.log.SetRequestSampling(sampling)
+		w.logConfig()(ctx)
+	}
+}
@@ -0,0 +1,143 @@
+package web
+
+import (
+	"bytes"
+	"runtime"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/valyala/fasthttp"
+
+	"github.com/ngalayko/highloadcup/app/datastore"
+	"github.com/ngalayko/highloadcup/app/metrics"
+)
+
+// webMetrics holds request metrics and values collected once per scrape.
+type webMetrics struct {
+	registry *metrics.Registry
+	requests *metrics.CounterVec
+	duration *metrics.HistogramVec
+
+	// scrapeMu guards values collected for a scrape.
+	scrapeMu sync.Mutex
+	mem      runtime.MemStats
+	stats    *datastore.Stats
+}
+
+func (w *Web) initMetrics() {
+	r := metrics.New()
+	m := &webMetrics{
+		registry: r,
+		requests: r.Counter("highloadcup_requests_total", "Number of requests by endpoint and status code.", "endpoint", "status"),
+		duration: r.Histogram("highloadcup_request_duration_seconds", "Request latency by endpoint.", metrics.DefaultBuckets, "endpoint"),
+	}
+
+	r.GaugeFunc("highloadcup_accounts", "Number of accounts.", func() float64 {
+		return float64(m.stats.Accounts)
+	})
+	r.GaugeFunc("highloadcup_pending_writes", "Number of writes not yet reflected in indexes.", func() float64 {
+		return float64(m.stats.Pending)
+	})
+	r.GaugeVecFunc("highloadcup_index_buckets", "Number of keys by index.", "index", func() map[string]float64 {
+		buckets := make(map[string]float64, len(m.stats.Buckets))
+		for name, n := range m.stats.Buckets {
+			buckets[name] = float64(n)
+		}
+		return buckets
+	})
+	r.CounterFunc("highloadcup_log_dropped_total", "Number of log entries dropped by the async writer.", func() float64 {
+		return float64(w.log.Dropped())
+	})
+
+	r.GaugeFunc("go_goroutines", "Number of goroutines.", func() float64 {
+		return float64(runtime.NumGoroutine())
+	})
+	r.CounterFunc("go_gc_cycles_total", "Number of completed GC cycles.", func() float64 {
+		return float64(m.mem.NumGC)
+	})
+	r.CounterFunc("go_gc_pause_seconds_total", "Total GC stop-the-world pause time.", func() float64 {
+		return time.Duration(m.mem.PauseTotalNs).Seconds()
+	})
+	r.GaugeFunc("go_memstats_heap_alloc_bytes", "Bytes of allocated heap objects.", func() float64 {
+		return float64(m.mem.HeapAlloc)
+	})
+	r.GaugeFunc("go_memstats_heap_objects", "Number of allocated heap objects.", func() float64 {
+		return float64(m.mem.HeapObjects)
+	})
+	r.GaugeFunc("go_memstats_sys_bytes", "Bytes of memory obtained from the OS.", func() float64 {
+		return float64(m.mem.Sys)
+	})
+
+	w.metrics = m
+}
+
+// observe records a handled request.
+func (w *Web) observe(ctx *fasthttp.RequestCtx, duration time.Duration) {
+	e := endpoint(ctx.Method(), ctx.Path())
+	w.metrics.requests.With(e, strconv.Itoa(ctx.Response.StatusCode())).Inc()
+	w.metrics.duration.With(e).Observe(duration.Seconds())
+}
+
+func (w *Web) metricsHandler() func(*fasthttp.RequestCtx) {
+	return func(ctx *fasthttp.RequestCtx) {
+		m := w.metrics
+
+		m.scrapeMu.Lock()
+		defer m.scrapeMu.Unlock()
+
+		runtime.ReadMemStats(&m.mem)
+		m.stats = &datastore.Stats{}
+		if w.isReady() {
+			m.stats = w.datastore.Stats()
+		}
+
+		ctx.Response.Header.SetContentType("text/plain; version=0.0.4")
+		ctx.SetStatusCode(fasthttp.StatusOK)
+		if err := m.registry.Write(ctx); err != nil {
+			w.log.Error("metrics: %s", err)
+		}
+	}
+}
+
+var (
+	recommendSuffix = []byte("/recommend/")
+	suggestSuffix   = []byte("/suggest/")
+)
+
+// endpoint returns a metrics label of a request.
+func endpoint(method []byte, path []byte) string {
+	switch string(path) {
+	case "/accounts/filter/":
+		return "filter"
+	case "/accounts/group/":
+		return "group"
+	case "/accounts/new/":
+		return "new"
+	case "/accounts/likes/":
+		return "likes"
+	case "/healthcheck", "/healthcheck/live", "/healthcheck/ready":
+		return "healthcheck"
+	case "/metrics":
+		return "metrics"
+	}
+
+	switch {
+	case bytes.HasPrefix(path, []byte("/admin/")):
+		return "admin"
+	case !bytes.HasPrefix(path, accountsPrefix):
+		return "unknown"
+	case bytes.HasSuffix(path, recommendSuffix):
+		return "recommend"
+	case bytes.HasSuffix(path, suggestSuffix):
+		return "suggest"
+	}
+
+	if _, ok := accountID(path); !ok {
+		return "unknown"
+	}
+	if string(method) == "POST" {
+		return "update"
+	}
+	return "account"
+}
@@ -0,0 +1,42 @@
+package web
+
+import (
+	"time"
+
+	"github.com/valyala/fasthttp"
+
+	"github.com/ngalayko/highloadcup/app/datastore"
+	"github.com/ngalayko/highloadcup/app/logger"
+)
+
+// WithSlowLog logs queries which took longer than a threshold in
+// the datastore with their plans to a log.
+func WithSlowLog(log *logger.Logger, threshold time.Duration) Option {
+	return func(w *Web) {
+		w.slowLog = log
+		w.slowThreshold = threshold
+	}
+}
+
+// plan returns a plan to record for a query, it returns nil if the slow log
+// is disabled.
+func (w *Web) plan(predicates []string) *datastore.Plan {
+	if w.slowLog == nil {
+		return nil
+	}
+	return datastore.NewPlan(predicates...)
+}
+
+// logSlow logs a query if it is slower than a threshold.
+func (w *Web) logSlow(ctx *fasthttp.RequestCtx, plan *datastore.Plan) {
+	if w.slowLog == nil || plan == nil || plan.Duration < w.slowThreshold {
+		return
+	}
+	w.slowLog.With(
+		logger.F("uri", ctx.RequestURI()),
+		logger.F("duration", plan.Duration),
+		logger.F("seed", plan.Seed),
+		logger.F("groups", plan.Groups),
+		logger.F("plan", plan.String()),
+	).Info("slow query")
+}
@@ -2,6 +2,9 @@ package web
 
 import (
 	"encoding/json"
+	"errors"
+	"net"
+	"sync"
 	"time"
 
 	"github.com/valyala/fasthttp"
@@ -16,64 +19,236 @@ type Web struct {
 	log           *logger.Logger
 	datastore     *datastore.Datastore
 	enableProfile bool
+	metrics       *webMetrics
+
+	slowLog       *logger.Logger
+	slowThreshold time.Duration
+	explain       bool
+	exportOptions []byte
+
+	progress *datastore.Progress
+	// ready is set once the datastore is loaded.
+	ready int32
+
+	mu      sync.Mutex
+	servers []*fasthttp.Server
+
+	// conns are open connections by their last state, they are closed
+	// for reading on Shutdown, so that idle keep-alive connections
+	// don't block it.
+	connMu   sync.Mutex
+	conns    map[net.Conn]fasthttp.ConnState
+	stopping bool
 }
 
-// New is a web constructor.
+// ErrShutdownTimeout is returned by Shutdown if in-flight requests were
+// not finished in time.
+var ErrShutdownTimeout = errors.New("shutdown timed out")
+
+// Option is a web option.
+type Option func(*Web)
+
+// New is a web constructor. If datastore is nil, only healthchecks,
+// metrics and log configuration are served until SetDatastore is called.
 func New(
 	log *logger.Logger,
 	datastore *datastore.Datastore,
+	opts ...Option,
 ) *Web {
-	return &Web{
-		log:       log,
-		datastore: datastore,
+	w := &Web{
+		log:   log,
+		conns: map[net.Conn]fasthttp.ConnState{},
+	}
+	for _, opt := range opts {
+		opt(w)
+	}
+	w.initMetrics()
+	if datastore != nil {
+		w.SetDatastore(datastore)
 	}
+	return w
 }
 
-// ListenAndServeProfile starts the server.
+// ListenAndServeProfile starts the server, it serves /metrics and
+// admin endpoints as well.
 func (w *Web) ListenAndServeProfile(addr string) error {
 	w.log.Info("starting profile server on %s", addr)
-	return fasthttp.ListenAndServe(addr, pprofhandler.PprofHandler)
+	return w.server(w.profileHandler).ListenAndServe(addr)
+}
+
+// profileHandler serves debug and admin endpoints, they are not exposed
+// on the main port.
+func (w *Web) profileHandler(ctx *fasthttp.RequestCtx) {
+	switch string(ctx.Path()) {
+	case "/metrics":
+		w.metricsHandler()(ctx)
+	case "/admin/export":
+		if !w.isReady() {
+			ctx.SetStatusCode(fasthttp.StatusServiceUnavailable)
+			return
+		}
+		w.export()(ctx)
+	case "/admin/log":
+		if string(ctx.Method()) == "POST" {
+			w.setLogConfig()(ctx)
+			return
+		}
+		w.logConfig()(ctx)
+	default:
+		pprofhandler.PprofHandler(ctx)
+	}
 }
 
 // ListenAndServe starts the server.
 func (w *Web) ListenAndServe(addr string) error {
 	w.log.Info("starting server on %s", addr)
+	return w.server(w.handler).ListenAndServe(addr)
+}
+
+// Serve serves requests from a listener.
+func (w *Web) Serve(ln net.Listener) error {
+	return w.server(w.handler).Serve(ln)
+}
 
+// server returns a new server tracked for Shutdown.
+func (w *Web) server(handler fasthttp.RequestHandler) *fasthttp.Server {
 	s := &fasthttp.Server{
-		Handler: w.handler,
+		Handler:   handler,
+		ConnState: w.connState,
 	}
-	return s.ListenAndServe(addr)
+
+	w.mu.Lock()
+	w.servers = append(w.servers, s)
+	w.mu.Unlock()
+	return s
+}
+
+// connState tracks open connections, connections becoming idle after
+// Shutdown is called are closed.
+func (w *Web) connState(c net.Conn, state fasthttp.ConnState) {
+	w.connMu.Lock()
+	defer w.connMu.Unlock()
+
+	switch state {
+	case fasthttp.StateClosed, fasthttp.StateHijacked:
+		delete(w.conns, c)
+		return
+	}
+	w.conns[c] = state
+	if w.stopping && state == fasthttp.StateIdle {
+		closeRead(c, state)
+	}
+}
+
+// closeRead closes a connection for reading, so that the server reads EOF
+// and closes it without errors after in-flight requests are answered.
+// Other than TCP connections are closed only if they are idle.
+func closeRead(c net.Conn, state fasthttp.ConnState) {
+	if tcp, ok := c.(*net.TCPConn); ok {
+		tcp.CloseRead()
+		return
+	}
+	if state == fasthttp.StateIdle {
+		c.Close()
+	}
+}
+
+// Shutdown stops accepting connections, closes idle keep-alive connections
+// and waits for in-flight requests to finish. It returns ErrShutdownTimeout
+// if requests are still in flight after timeout.
+func (w *Web) Shutdown(timeout time.Duration) error {
+	w.mu.Lock()
+	servers := w.servers
+	w.servers = nil
+	w.mu.Unlock()
+
+	w.connMu.Lock()
+	w.stopping = true
+	for c, state := range w.conns {
+		closeRead(c, state)
+	}
+	w.connMu.Unlock()
+
+	done := make(chan error, len(servers))
+	for _, s := range servers {
+		go func(s *fasthttp.Server) {
+			done <- s.Shutdown()
+		}(s)
+	}
+
+	deadline := time.NewTimer(timeout)
+	defer deadline.Stop()
+
+	var err error
+	for range servers {
+		select {
+		case e := <-done:
+			if e != nil && err == nil {
+				err = e
+			}
+		case <-deadline.C:
+			return ErrShutdownTimeout
+		}
+	}
+	return err
 }
 
 func (w *Web) handler(ctx *fasthttp.RequestCtx) {
 	start := time.Now()
-	switch string(ctx.Method()) {
-	case "GET":
+	switch {
+	case !w.isReady() && !servedLoading(ctx.Path()):
+		ctx.SetStatusCode(fasthttp.StatusServiceUnavailable)
+	case string(ctx.Method()) == "GET":
 		w.handlerGET(ctx)
-	case "POST":
+	case string(ctx.Method()) == "POST":
 		w.handlerPOST(ctx)
 	default:
 		ctx.SetStatusCode(fasthttp.StatusNotFound)
 	}
-	w.log.Info("%s %s", ctx.URI(), time.Since(start))
+	duration := time.Since(start)
+	w.observe(ctx, duration)
+
+	if w.log.SampleRequest() {
+		w.log.With(
+			logger.F("method", ctx.Method()),
+			logger.F("uri", ctx.RequestURI()),
+			logger.F("status", ctx.Response.StatusCode()),
+			logger.F("duration", duration),
+		).Info("request")
+	}
 }
 
 func (w *Web) handlerPOST(ctx *fasthttp.RequestCtx) {
 	switch string(ctx.Path()) {
+	case "/accounts/new/":
+		w.newAccount()(ctx)
+	case "/accounts/likes/":
+		w.addLikes()(ctx)
 	default:
+		if id, ok := accountID(ctx.Path()); ok {
+			w.updateAccount(id)(ctx)
+			return
+		}
 		ctx.SetStatusCode(fasthttp.StatusNotFound)
 	}
 }
 
 func (w *Web) handlerGET(ctx *fasthttp.RequestCtx) {
 	switch string(ctx.Path()) {
-	case "/healthcheck":
-		w.healthcheck()(ctx)
+	// healthcheck is the readiness check used by the tester.
+	case "/healthcheck", "/healthcheck/ready":
+		w.readiness()(ctx)
+	case "/healthcheck/live":
+		w.live()(ctx)
 	case "/accounts/filter/":
 		w.accountsFilter()(ctx)
 	case "/accounts/group/":
 		w.accountsGroup()(ctx)
 	default:
+		if id, ok := accountID(ctx.Path()); ok {
+			w.account(id)(ctx)
+			return
+		}
 		ctx.SetStatusCode(fasthttp.StatusNotFound)
 	}
 }
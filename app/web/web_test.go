@@ -0,0 +1,485 @@
+package web
+
+import (
+	"bufio"
+	"encoding/json"
+	"io/ioutil"
+	"log"
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/valyala/fasthttp"
+	"github.com/valyala/fasthttp/fasthttputil"
+
+	"github.com/ngalayko/highloadcup/app/datastore"
+	exportzip "github.com/ngalayko/highloadcup/app/exporter/zip"
+	"github.com/ngalayko/highloadcup/app/generator"
+	"github.com/ngalayko/highloadcup/app/importer/zip"
+	"github.com/ngalayko/highloadcup/app/logger"
+)
+
+// newWeb returns web with a small generated dataset.
+func newWeb(t testing.TB) *Web {
+	d, err := datastore.New(logger.New(), zip.New(writeData(t)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return New(logger.New(), d)
+}
+
+// writeData writes a small generated dataset and returns its path.
+func writeData(t testing.TB) string {
+	log.SetOutput(ioutil.Discard)
+
+	path := filepath.Join(t.TempDir(), "data.zip")
+	file, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	aa := generator.New(&generator.Config{
+		Accounts: 300,
+		Seed:     1,
+		Now:      1545267613,
+	}).Generate()
+	if err := exportzip.New(file).Write(aa); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func (w *Web) get(path string, query string) (int, []byte) {
+	ctx := &fasthttp.RequestCtx{}
+	ctx.Request.Header.SetMethod("GET")
+	ctx.Request.SetRequestURI(path)
+	ctx.Request.URI().SetQueryString(query)
+	w.handler(ctx)
+	return ctx.Response.StatusCode(), ctx.Response.Body()
+}
+
+func (w *Web) post(path string, body string) (int, []byte) {
+	ctx := &fasthttp.RequestCtx{}
+	ctx.Request.Header.SetMethod("POST")
+	ctx.Request.SetRequestURI(path)
+	ctx.Request.URI().SetQueryString("query_id=1")
+	ctx.Request.SetBodyString(body)
+	w.handler(ctx)
+	return ctx.Response.StatusCode(), ctx.Response.Body()
+}
+
+func (w *Web) profile(method string, path string) (int, []byte) {
+	ctx := &fasthttp.RequestCtx{}
+	ctx.Request.Header.SetMethod(method)
+	ctx.Request.SetRequestURI(path)
+	w.profileHandler(ctx)
+	return ctx.Response.StatusCode(), ctx.Response.Body()
+}
+
+// reverse returns a query with parameters in reverse order.
+func reverse(query string) string {
+	params := strings.Split(query, "&")
+	for i, j := 0, len(params)-1; i < j; i, j = i+1, j-1 {
+		params[i], params[j] = params[j], params[i]
+	}
+	return strings.Join(params, "&")
+}
+
+// checkQuery requests path with a query in given and reverse parameters order,
+// and checks that status codes are the same, and successful responses
+// are lists of at most limit elements.
+func checkQuery(t *testing.T, w *Web, path string, query string, list string) []map[string]interface{} {
+	status, body := w.get(path, query)
+	if status != fasthttp.StatusOK && status != fasthttp.StatusBadRequest {
+		t.Fatalf("%s: unexpected status %d", query, status)
+	}
+
+	if reversed, _ := w.get(path, reverse(query)); reversed != status {
+		t.Fatalf("%s: status %d depends on parameters order, got %d", query, status, reversed)
+	}
+
+	if status != fasthttp.StatusOK {
+		return nil
+	}
+
+	res := map[string][]map[string]interface{}{}
+	if err := json.Unmarshal(body, &res); err != nil {
+		t.Fatalf("%s: invalid response %s: %s", query, body, err)
+	}
+	items, exists := res[list]
+	if !exists {
+		t.Fatalf("%s: no %s in response %s", query, list, body)
+	}
+
+	// the last limit is used if there are many of them.
+	args := &fasthttp.Args{}
+	args.Parse(query)
+	limit := 0
+	args.VisitAll(func(key, value []byte) {
+		if string(key) == "limit" {
+			limit, _ = strconv.Atoi(string(value))
+		}
+	})
+	if len(items) > limit {
+		t.Fatalf("%s: %d %s is more than limit", query, len(items), list)
+	}
+	return items
+}
+
+func TestReadiness(t *testing.T) {
+	path := writeData(t)
+	progress := datastore.NewProgress()
+	w := New(logger.New(), nil, WithProgress(progress))
+
+	for path, expected := range map[string]int{
+		"/healthcheck/live":     fasthttp.StatusOK,
+		"/healthcheck/ready":    fasthttp.StatusServiceUnavailable,
+		"/healthcheck":          fasthttp.StatusServiceUnavailable,
+		"/accounts/filter/":     fasthttp.StatusServiceUnavailable,
+		"/accounts/1/":          fasthttp.StatusServiceUnavailable,
+		"/accounts/1/unknown/":  fasthttp.StatusServiceUnavailable,
+		"/healthcheck/unknown/": fasthttp.StatusServiceUnavailable,
+	} {
+		if status, _ := w.get(path, "limit=1"); status != expected {
+			t.Fatalf("%s: expected %d while loading, got %d", path, expected, status)
+		}
+	}
+
+	d, err := datastore.New(logger.New(), zip.New(path), datastore.WithProgress(progress))
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.SetDatastore(d)
+
+	status, body := w.get("/healthcheck/ready", "")
+	if status != fasthttp.StatusOK {
+		t.Fatalf("expected ready, got %d", status)
+	}
+	res := &readinessResponse{}
+	if err := json.Unmarshal(body, res); err != nil {
+		t.Fatal(err)
+	}
+	if !res.Ready || res.Stage != datastore.StageReady || res.Files != res.TotalFiles || res.Files == 0 || res.Accounts != 300 {
+		t.Fatalf("unexpected readiness %s", body)
+	}
+
+	if status, _ := w.get("/accounts/filter/", "limit=1"); status != fasthttp.StatusOK {
+		t.Fatalf("expected filter to be served, got %d", status)
+	}
+}
+
+func TestWrite(t *testing.T) {
+	w := newWeb(t)
+	taken, _ := w.datastore.Account(1)
+
+	for _, c := range []struct {
+		path   string
+		body   string
+		status int
+	}{
+		{"/accounts/new/", `{"id":100000,"email":"new@mail.ru","sex":"m","status":"свободны","birth":1,"joined":1330000000}`, fasthttp.StatusCreated},
+		{"/accounts/new/", `{"id":100000,"email":"other@mail.ru","sex":"m","status":"свободны"}`, fasthttp.StatusBadRequest},
+		{"/accounts/new/", `{"id":100001,"email":"` + taken.Email + `","sex":"m","status":"свободны"}`, fasthttp.StatusBadRequest},
+		{"/accounts/new/", `{"id":100002`, fasthttp.StatusBadRequest},
+		{"/accounts/100000/", `{"status":"заняты","city":"Москва"}`, fasthttp.StatusAccepted},
+		{"/accounts/100000/", `{"city":null}`, fasthttp.StatusBadRequest},
+		{"/accounts/100000/", `{"email":"` + taken.Email + `"}`, fasthttp.StatusBadRequest},
+		{"/accounts/100000/", `{"sex":"x"}`, fasthttp.StatusBadRequest},
+		{"/accounts/200000/", `{"status":"заняты"}`, fasthttp.StatusNotFound},
+		{"/accounts/likes/", `{"likes":[{"likee":1,"liker":100000,"ts":1540000000}]}`, fasthttp.StatusAccepted},
+		{"/accounts/likes/", `{"likes":[{"likee":1,"liker":200000,"ts":1540000000}]}`, fasthttp.StatusBadRequest},
+		{"/accounts/likes/", `{"likes":[null]}`, fasthttp.StatusBadRequest},
+		{"/accounts/unknown/", `{}`, fasthttp.StatusNotFound},
+	} {
+		status, body := w.post(c.path, c.body)
+		if status != c.status {
+			t.Fatalf("%s %s: expected %d, got %d", c.path, c.body, c.status, status)
+		}
+		if status < fasthttp.StatusBadRequest && string(body) != "{}" {
+			t.Fatalf("%s %s: expected an empty object, got %s", c.path, c.body, body)
+		}
+	}
+
+	a, exists := w.datastore.Account(100000)
+	if !exists {
+		t.Fatal("expected a created account")
+	}
+	if a.Status != "заняты" || a.City != "Москва" || a.Email != "new@mail.ru" {
+		t.Fatalf("unexpected updated account %+v", a)
+	}
+	if len(a.Likes) != 1 || a.Likes[0].ID != 1 {
+		t.Fatalf("expected a like, got %+v", a.Likes)
+	}
+
+	if err := w.datastore.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if status, _ := w.post("/accounts/100000/", `{"status":"свободны"}`); status != fasthttp.StatusServiceUnavailable {
+		t.Fatalf("expected 503 after the datastore is closed, got %d", status)
+	}
+}
+
+func TestAdmin(t *testing.T) {
+	w := newWeb(t)
+
+	for _, path := range []string{"/metrics", "/admin/export", "/admin/log"} {
+		if status, _ := w.get(path, ""); status != fasthttp.StatusNotFound {
+			t.Fatalf("%s: expected 404 on the main port, got %d", path, status)
+		}
+		if status, _ := w.profile("GET", path); status != fasthttp.StatusOK {
+			t.Fatalf("%s: expected 200 on the profile port, got %d", path, status)
+		}
+	}
+
+	if status, _ := w.post("/admin/log", ""); status != fasthttp.StatusNotFound {
+		t.Fatalf("expected log config not to be changed on the main port, got %d", status)
+	}
+	if status, _ := w.profile("POST", "/admin/log?level=error"); status != fasthttp.StatusOK {
+		t.Fatalf("expected log config to be changed on the profile port, got %d", status)
+	}
+	if level := w.log.Level(); level != logger.ErrorLevel {
+		t.Fatalf("expected error level, got %s", level)
+	}
+}
+
+func TestShutdown(t *testing.T) {
+	w := New(logger.New(), nil)
+	ln := fasthttputil.NewInmemoryListener()
+
+	served := make(chan error, 1)
+	go func() {
+		served <- w.Serve(ln)
+	}()
+
+	c := &fasthttp.HostClient{
+		Addr: "localhost",
+		Dial: func(string) (net.Conn, error) {
+			return ln.Dial()
+		},
+	}
+	req := fasthttp.AcquireRequest()
+	defer fasthttp.ReleaseRequest(req)
+	resp := fasthttp.AcquireResponse()
+	defer fasthttp.ReleaseResponse(resp)
+
+	req.SetRequestURI("http://localhost/healthcheck/live")
+	req.SetConnectionClose()
+	if err := c.Do(req, resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode() != fasthttp.StatusOK {
+		t.Fatalf("expected live, got %d", resp.StatusCode())
+	}
+
+	if err := w.Shutdown(time.Second); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case err := <-served:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("server is still serving after shutdown")
+	}
+
+	if err := c.Do(req, resp); err == nil {
+		t.Fatal("expected connections to be refused after shutdown")
+	}
+}
+
+func TestExplain(t *testing.T) {
+	w := newWeb(t)
+
+	if status, _ := w.get("/accounts/filter/", "limit=5&sex_eq=m&explain=1"); status != fasthttp.StatusBadRequest {
+		t.Fatalf("expected explain to be disabled, got %d", status)
+	}
+
+	WithExplain()(w)
+	for path, query := range map[string]string{
+		"/accounts/filter/": "limit=5&sex_eq=m&city_null=0&explain=1",
+		"/accounts/group/":  "limit=5&keys=city&order=1&sex=m&explain=1",
+	} {
+		status, body := w.get(path, query)
+		if status != fasthttp.StatusOK {
+			t.Fatalf("%s: unexpected status %d", query, status)
+		}
+
+		res := &explainResponse{}
+		if err := json.Unmarshal(body, res); err != nil {
+			t.Fatalf("%s: invalid response %s: %s", query, body, err)
+		}
+		if res.Seed != "bySex" || len(res.Steps) != 2 || res.Results == 0 || res.Results > 5 {
+			t.Fatalf("%s: unexpected plan %s", query, body)
+		}
+		if first := res.Steps[0]; first.Estimated == nil || *first.Estimated != first.Actual {
+			t.Fatalf("%s: expected exact estimate of the first step, got %s", query, body)
+		}
+	}
+}
+
+func FuzzFilter(f *testing.F) {
+	f.Add("query_id=1&limit=10")
+	f.Add("query_id=1&limit=-1")
+	f.Add("limit=5&sex_eq=m&status_neq=%D0%B7%D0%B0%D0%BD%D1%8F%D1%82%D1%8B")
+	f.Add("limit=5&birth_lt=x")
+	f.Add("birth_lt=x&limit=5")
+	f.Add("limit=20&interests_any=%D0%9F%D0%B8%D0%B2%D0%BE,PS3&phone_null=0")
+	f.Add("limit=20&likes_contains=1,2&premium_now=1")
+	f.Add("limit=3&country_size=1")
+	f.Add("limit=3&city_any=&sname_starts=%D0")
+
+	w := newWeb(f)
+	f.Fuzz(func(t *testing.T, query string) {
+		aa := checkQuery(t, w, "/accounts/filter/", query, "accounts")
+		for i := 1; i < len(aa); i++ {
+			if aa[i-1]["id"].(float64) <= aa[i]["id"].(float64) {
+				t.Fatalf("%s: accounts are not ordered by id", query)
+			}
+		}
+	})
+}
+
+func FuzzGroup(f *testing.F) {
+	f.Add("query_id=1&keys=sex&order=1&limit=10")
+	f.Add("keys=interests,city&order=-1&limit=5&sex=f")
+	f.Add("keys=country&order=2&limit=5")
+	f.Add("keys=country,email&order=1&limit=5")
+	f.Add("keys=status&order=1&limit=-5")
+	f.Add("keys=status&order=-1&limit=5&birth=x")
+	f.Add("keys=city&order=1&limit=5&likes=1&joined=2015&interests=PS3")
+
+	w := newWeb(f)
+	f.Fuzz(func(t *testing.T, query string) {
+		for _, g := range checkQuery(t, w, "/accounts/group/", query, "groups") {
+			if count, _ := g["count"].(float64); count <= 0 {
+				t.Fatalf("%s: invalid group %v", query, g)
+			}
+		}
+	})
+}
+
+// TestGroupAnswers checks served groups against the reference answers.
+func TestGroupAnswers(t *testing.T) {
+	d, err := datastore.New(logger.New(), zip.New("../../test_data/data/data.zip"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := New(logger.New(), d)
+
+	file, err := os.Open("../../test_data/answers/phase_1_get.answ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(nil, 1<<20)
+	checked := 0
+	for scanner.Scan() {
+		parts := strings.SplitN(scanner.Text(), "\t", 4)
+		if len(parts) < 3 || !strings.HasPrefix(parts[1], "/accounts/group/?") {
+			continue
+		}
+		checked++
+
+		query := strings.TrimPrefix(parts[1], "/accounts/group/?")
+		status, body := w.get("/accounts/group/", query)
+		if strconv.Itoa(status) != parts[2] {
+			t.Fatalf("%s: expected status %s, got %d", query, parts[2], status)
+		}
+		if len(parts) < 4 {
+			continue
+		}
+
+		var expected, got interface{}
+		if err := json.Unmarshal([]byte(parts[3]), &expected); err != nil {
+			t.Fatal(err)
+		}
+		if err := json.Unmarshal(body, &got); err != nil {
+			t.Fatalf("%s: invalid response %s: %s", query, body, err)
+		}
+		if !reflect.DeepEqual(got, expected) {
+			t.Fatalf("%s: expected %s, got %s", query, parts[3], body)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if checked == 0 {
+		t.Fatal("no group answers checked")
+	}
+}
+
+// serve serves a handler tracked for Shutdown over tcp and returns
+// a keep-alive client.
+func serve(t *testing.T, w *Web, handler fasthttp.RequestHandler) *fasthttp.HostClient {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go w.server(handler).Serve(ln)
+
+	return &fasthttp.HostClient{
+		Addr: ln.Addr().String(),
+	}
+}
+
+func TestShutdownKeepAlive(t *testing.T) {
+	w := New(logger.New(), nil)
+	c := serve(t, w, w.handler)
+
+	// the connection stays open and idle after a response.
+	if status, _, err := c.Get(nil, "http://"+c.Addr+"/healthcheck/live"); err != nil || status != fasthttp.StatusOK {
+		t.Fatalf("expected live, got %d: %v", status, err)
+	}
+
+	start := time.Now()
+	if err := w.Shutdown(5 * time.Second); err != nil {
+		t.Fatalf("expected idle connections to be closed, got %s", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("shutdown waited for an idle connection for %s", elapsed)
+	}
+}
+
+func TestShutdownInFlight(t *testing.T) {
+	w := New(logger.New(), nil)
+	started := make(chan struct{})
+	release := make(chan struct{})
+	c := serve(t, w, func(ctx *fasthttp.RequestCtx) {
+		close(started)
+		<-release
+		ctx.SetStatusCode(fasthttp.StatusOK)
+	})
+
+	responded := make(chan int, 1)
+	go func() {
+		status, _, err := c.Get(nil, "http://"+c.Addr+"/")
+		if err != nil {
+			t.Error(err)
+		}
+		responded <- status
+	}()
+	<-started
+
+	if err := w.Shutdown(200 * time.Millisecond); err != ErrShutdownTimeout {
+		t.Fatalf("expected shutdown to time out waiting for a request, got %v", err)
+	}
+
+	close(release)
+	select {
+	case status := <-responded:
+		if status != fasthttp.StatusOK {
+			t.Fatalf("expected an in-flight request to finish, got %d", status)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("in-flight request is not finished")
+	}
+}
@@ -0,0 +1,63 @@
+package web
+
+import (
+	"github.com/valyala/fasthttp"
+
+	"github.com/ngalayko/highloadcup/app/datastore"
+)
+
+var emptyResponse = []byte("{}")
+
+func (w *Web) newAccount() func(*fasthttp.RequestCtx) {
+	return func(ctx *fasthttp.RequestCtx) {
+		err := w.datastore.NewAccount(ctx.PostBody())
+		switch {
+		case err == datastore.ErrClosed:
+			ctx.SetStatusCode(fasthttp.StatusServiceUnavailable)
+		case err != nil:
+			w.error(ctx, err)
+		default:
+			w.responseEmpty(ctx, fasthttp.StatusCreated)
+		}
+	}
+}
+
+func (w *Web) updateAccount(id int64) func(*fasthttp.RequestCtx) {
+	return func(ctx *fasthttp.RequestCtx) {
+		err := w.datastore.UpdateAccount(id, ctx.PostBody())
+		switch {
+		case err == datastore.ErrNotFound:
+			ctx.SetStatusCode(fasthttp.StatusNotFound)
+		case err == datastore.ErrClosed:
+			ctx.SetStatusCode(fasthttp.StatusServiceUnavailable)
+		case err != nil:
+			w.error(ctx, err)
+		default:
+			w.responseEmpty(ctx, fasthttp.StatusAccepted)
+		}
+	}
+}
+
+func (w *Web) addLikes() func(*fasthttp.RequestCtx) {
+	return func(ctx *fasthttp.RequestCtx) {
+		// likes of unknown accounts are invalid, not missing.
+		err := w.datastore.AddLikes(ctx.PostBody())
+		switch {
+		case err == datastore.ErrClosed:
+			ctx.SetStatusCode(fasthttp.StatusServiceUnavailable)
+		case err != nil:
+			w.error(ctx, err)
+		default:
+			w.responseEmpty(ctx, fasthttp.StatusAccepted)
+		}
+	}
+}
+
+// responseEmpty writes an empty json object with a status code.
+func (w *Web) responseEmpty(ctx *fasthttp.RequestCtx, statusCode int) {
+	ctx.Response.Header.Add("Connection", "keep-alive")
+	ctx.Response.Header.SetContentType("application/json")
+
+	ctx.SetStatusCode(statusCode)
+	ctx.Write(emptyResponse)
+}
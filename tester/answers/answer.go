@@ -1,35 +1,46 @@
 package answers
 
 import (
-	"encoding/json"
+	"bytes"
+	"fmt"
+	"io"
 	"io/ioutil"
 	"net/http"
 	"net/url"
-	"reflect"
 	"time"
 )
 
 // Answer is a single test data entry.
 type Answer struct {
-	Method     string                 `json:",omitempty"`
-	StatusCode int                    `json:",omitempty"`
-	URL        *url.URL               `json:",omitempty"`
-	Response   map[string]interface{} `json:",omitempty"`
+	Method      string                 `json:",omitempty"`
+	StatusCode  int                    `json:",omitempty"`
+	URL         *url.URL               `json:",omitempty"`
+	Response    map[string]interface{} `json:",omitempty"`
+	Body        string                 `json:",omitempty"`
+	ContentType string                 `json:",omitempty"`
 }
 
+// Report is a result of an answer test.
 type Report struct {
-	Expected     *Answer  `json:"expected"`
-	Got          *Answer  `json:"got"`
-	URL          *url.URL `json:"url"`
-	ResponseTime string   `json:"response_time"`
+	Expected     *Answer       `json:"expected"`
+	Got          *Answer       `json:"got"`
+	URL          *url.URL      `json:"url"`
+	Method       string        `json:"method"`
+	Success      bool          `json:"success"`
+	Body         string        `json:"body,omitempty"`
+	Diff         *Diff         `json:"diff,omitempty"`
+	ResponseTime time.Duration `json:"response_time_ns"`
 }
 
-// Test tests an answer.
-func (a *Answer) Test() (bool, *Report, error) {
-	req, err := http.NewRequest(a.Method, a.URL.String(), http.NoBody)
+// Test tests an answer using a client.
+func (a *Answer) Test(client *http.Client) (bool, *Report, error) {
+	req, err := http.NewRequest(a.Method, a.URL.String(), a.body())
 	if err != nil {
 		return false, nil, err
 	}
+	if a.Body != "" {
+		req.Header.Set("Content-Type", a.contentType())
+	}
 
 	report := &Report{
 		Expected: &Answer{},
@@ -38,24 +49,23 @@ func (a *Answer) Test() (bool, *Report, error) {
 
 	start := time.Now()
 
-	resp, err := http.DefaultClient.Do(req)
+	resp, err := client.Do(req)
 	if err != nil {
 		return false, nil, err
 	}
 	defer resp.Body.Close()
 
-	report.ResponseTime = time.Since(start).String()
+	report.ResponseTime = time.Since(start)
 
 	body, err := ioutil.ReadAll(resp.Body)
 	if err != nil {
 		return false, nil, err
 	}
 
-	jsonBody := map[string]interface{}{}
-	_ = json.Unmarshal(body, &jsonBody)
-
 	success := true
 	report.URL = a.URL
+	report.Method = a.Method
+	report.Body = a.Body
 
 	if resp.StatusCode != a.StatusCode {
 		report.Expected.StatusCode = a.StatusCode
@@ -63,11 +73,38 @@ func (a *Answer) Test() (bool, *Report, error) {
 		success = false
 	}
 
-	if !reflect.DeepEqual(a.Response, jsonBody) {
-		report.Expected.Response = a.Response
-		report.Got.Response = jsonBody
+	if a.Response == nil {
+		report.Success = success
+		return success, report, nil
+	}
+
+	jsonBody, err := decode(body)
+	if err != nil {
+		report.Diff = &Diff{
+			Error: fmt.Sprintf("can't parse response: %s", err),
+		}
+		return false, report, nil
+	}
+
+	if diff := diffResponses(a.Response, jsonBody); !diff.Empty() {
+		report.Diff = diff
 		success = false
 	}
 
+	report.Success = success
 	return success, report, nil
 }
+
+func (a *Answer) body() io.Reader {
+	if a.Body == "" {
+		return http.NoBody
+	}
+	return bytes.NewBufferString(a.Body)
+}
+
+func (a *Answer) contentType() string {
+	if a.ContentType == "" {
+		return "application/json"
+	}
+	return a.ContentType
+}
@@ -0,0 +1,162 @@
+package answers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// Diff is a difference between expected and got responses.
+type Diff struct {
+	Error   string       `json:"error,omitempty"`
+	Missing []string     `json:"missing,omitempty"`
+	Extra   []string     `json:"extra,omitempty"`
+	Order   []*OrderDiff `json:"order,omitempty"`
+	Fields  []*FieldDiff `json:"fields,omitempty"`
+}
+
+// OrderDiff is a list item found at a wrong position.
+type OrderDiff struct {
+	Key      string `json:"key"`
+	Expected int    `json:"expected"`
+	Got      int    `json:"got"`
+}
+
+// FieldDiff is a field with a different value.
+type FieldDiff struct {
+	Key      string      `json:"key,omitempty"`
+	Field    string      `json:"field"`
+	Expected interface{} `json:"expected,omitempty"`
+	Got      interface{} `json:"got,omitempty"`
+}
+
+// Empty returns true if there is no difference.
+func (d *Diff) Empty() bool {
+	return d.Error == "" &&
+		len(d.Missing) == 0 &&
+		len(d.Extra) == 0 &&
+		len(d.Order) == 0 &&
+		len(d.Fields) == 0
+}
+
+// decode decodes json object keeping numbers as json.Number.
+func decode(data []byte) (map[string]interface{}, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+
+	v := map[string]interface{}{}
+	if err := dec.Decode(&v); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// diffResponses compares responses. Lists of accounts and groups are
+// compared item by item using account id or group keys as an item key.
+func diffResponses(expected map[string]interface{}, got map[string]interface{}) *Diff {
+	d := &Diff{}
+	for _, field := range fieldNames(expected, got) {
+		ev, eExists := expected[field]
+		gv, gExists := got[field]
+		el, eList := ev.([]interface{})
+		gl, gList := gv.([]interface{})
+		switch {
+		case eExists && gExists && eList && gList:
+			d.diffList(field, el, gl)
+		case !reflect.DeepEqual(ev, gv):
+			d.Fields = append(d.Fields, &FieldDiff{
+				Field:    field,
+				Expected: ev,
+				Got:      gv,
+			})
+		}
+	}
+	return d
+}
+
+func (d *Diff) diffList(field string, expected []interface{}, got []interface{}) {
+	gotByKey := make(map[string]map[string]interface{}, len(got))
+	gotPosition := make(map[string]int, len(got))
+	for i, item := range got {
+		obj, _ := item.(map[string]interface{})
+		key := itemKey(field, obj)
+		gotByKey[key] = obj
+		gotPosition[key] = i
+	}
+
+	expectedKeys := make(map[string]bool, len(expected))
+	for i, item := range expected {
+		obj, _ := item.(map[string]interface{})
+		key := itemKey(field, obj)
+		expectedKeys[key] = true
+
+		gotObj, exists := gotByKey[key]
+		if !exists {
+			d.Missing = append(d.Missing, key)
+			continue
+		}
+
+		if gotPosition[key] != i {
+			d.Order = append(d.Order, &OrderDiff{
+				Key:      key,
+				Expected: i,
+				Got:      gotPosition[key],
+			})
+		}
+
+		for _, f := range fieldNames(obj, gotObj) {
+			if reflect.DeepEqual(obj[f], gotObj[f]) {
+				continue
+			}
+			d.Fields = append(d.Fields, &FieldDiff{
+				Key:      key,
+				Field:    f,
+				Expected: obj[f],
+				Got:      gotObj[f],
+			})
+		}
+	}
+
+	for _, item := range got {
+		obj, _ := item.(map[string]interface{})
+		if key := itemKey(field, obj); !expectedKeys[key] {
+			d.Extra = append(d.Extra, key)
+		}
+	}
+}
+
+// itemKey returns account id or group keys of a list item.
+func itemKey(field string, obj map[string]interface{}) string {
+	if id, exists := obj["id"]; exists {
+		return fmt.Sprintf("id=%v", id)
+	}
+
+	parts := make([]string, 0, len(obj))
+	for _, f := range fieldNames(obj) {
+		if field == "groups" && f == "count" {
+			continue
+		}
+		parts = append(parts, fmt.Sprintf("%s=%v", f, obj[f]))
+	}
+	return strings.Join(parts, ",")
+}
+
+// fieldNames returns sorted union of object keys.
+func fieldNames(objects ...map[string]interface{}) []string {
+	set := map[string]bool{}
+	for _, obj := range objects {
+		for key := range obj {
+			set[key] = true
+		}
+	}
+
+	names := make([]string, 0, len(set))
+	for name := range set {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
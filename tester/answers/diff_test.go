@@ -0,0 +1,115 @@
+package answers
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestDiffResponses(t *testing.T) {
+	for _, c := range []struct {
+		name     string
+		expected string
+		got      string
+		diff     *Diff
+	}{
+		{
+			name:     "equal",
+			expected: `{"accounts":[{"id":1,"email":"a@mail.ru"},{"id":2}]}`,
+			got:      `{"accounts":[{"email":"a@mail.ru","id":1},{"id":2}]}`,
+			diff:     &Diff{},
+		},
+		{
+			name:     "account field mismatch",
+			expected: `{"accounts":[{"id":1,"email":"a@mail.ru","city":"Москва"}]}`,
+			got:      `{"accounts":[{"id":1,"email":"b@mail.ru"}]}`,
+			diff: &Diff{
+				Fields: []*FieldDiff{
+					{Key: "id=1", Field: "city", Expected: "Москва"},
+					{Key: "id=1", Field: "email", Expected: "a@mail.ru", Got: "b@mail.ru"},
+				},
+			},
+		},
+		{
+			name:     "group count mismatch",
+			expected: `{"groups":[{"sex":"m","count":2},{"sex":"f","count":1}]}`,
+			got:      `{"groups":[{"sex":"m","count":3},{"sex":"f","count":1}]}`,
+			diff: &Diff{
+				Fields: []*FieldDiff{
+					{Key: "sex=m", Field: "count", Expected: json.Number("2"), Got: json.Number("3")},
+				},
+			},
+		},
+		{
+			name:     "missing and extra items",
+			expected: `{"accounts":[{"id":3},{"id":2}]}`,
+			got:      `{"accounts":[{"id":3},{"id":1}]}`,
+			diff: &Diff{
+				Missing: []string{"id=2"},
+				Extra:   []string{"id=1"},
+			},
+		},
+		{
+			name:     "order mismatch",
+			expected: `{"accounts":[{"id":3},{"id":2},{"id":1}]}`,
+			got:      `{"accounts":[{"id":2},{"id":3},{"id":1}]}`,
+			diff: &Diff{
+				Order: []*OrderDiff{
+					{Key: "id=3", Expected: 0, Got: 1},
+					{Key: "id=2", Expected: 1, Got: 0},
+				},
+			},
+		},
+		{
+			name:     "group order mismatch",
+			expected: `{"groups":[{"city":"Москва","sex":"f","count":1},{"sex":"m","count":1}]}`,
+			got:      `{"groups":[{"sex":"m","count":1},{"city":"Москва","sex":"f","count":1}]}`,
+			diff: &Diff{
+				Order: []*OrderDiff{
+					{Key: "city=Москва,sex=f", Expected: 0, Got: 1},
+					{Key: "sex=m", Expected: 1, Got: 0},
+				},
+			},
+		},
+		{
+			name:     "missing list",
+			expected: `{"accounts":[{"id":1}]}`,
+			got:      `{}`,
+			diff: &Diff{
+				Fields: []*FieldDiff{
+					{Field: "accounts", Expected: []interface{}{map[string]interface{}{"id": json.Number("1")}}},
+				},
+			},
+		},
+		{
+			name:     "not a list",
+			expected: `{"accounts":[]}`,
+			got:      `{"accounts":null}`,
+			diff: &Diff{
+				Fields: []*FieldDiff{
+					{Field: "accounts", Expected: []interface{}{}},
+				},
+			},
+		},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			expected, err := decode([]byte(c.expected))
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := decode([]byte(c.got))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			diff := diffResponses(expected, got)
+			if !reflect.DeepEqual(diff, c.diff) {
+				data, _ := json.Marshal(diff)
+				t.Fatalf("unexpected diff %s", data)
+			}
+			if diff.Empty() != (c.name == "equal") {
+				t.Fatalf("expected empty diff to be %t", c.name == "equal")
+			}
+		})
+	}
+}
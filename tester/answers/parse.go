@@ -2,12 +2,15 @@ package answers
 
 import (
 	"bufio"
-	"encoding/json"
+	"fmt"
 	"log"
+	"net/http"
 	"net/url"
 	"os"
 	"strconv"
 	"strings"
+
+	"github.com/ngalayko/highloadcup/app/ammo"
 )
 
 // Parse parses answers from a file.
@@ -55,7 +58,7 @@ func parseAnswer(baseURL *url.URL, line string) (*Answer, error) {
 		case 2:
 			ans.StatusCode, err = strconv.Atoi(s)
 		case 3:
-			err = json.Unmarshal([]byte(s), &ans.Response)
+			ans.Response, err = decode([]byte(s))
 		}
 		if err != nil {
 			break
@@ -66,3 +69,64 @@ func parseAnswer(baseURL *url.URL, line string) (*Answer, error) {
 	}
 	return ans, nil
 }
+
+// ParseBodies parses a companion body file of answers, tab separated lines
+// of method, uri and a json request body, into requests to attach.
+func ParseBodies(dataPath string) ([]*ammo.Request, error) {
+	file, err := os.Open(dataPath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	requests := []*ammo.Request{}
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(nil, 1024*1024)
+	for scanner.Scan() {
+		if scanner.Text() == "" {
+			continue
+		}
+		parts := strings.SplitN(scanner.Text(), "	", 3)
+		if len(parts) != 3 {
+			return nil, fmt.Errorf("invalid body line %d, expected method, uri and body", len(requests)+1)
+		}
+
+		requests = append(requests, &ammo.Request{
+			Method: parts[0],
+			URI:    parts[1],
+			Header: http.Header{"Content-Type": []string{"application/json"}},
+			Body:   []byte(parts[2]),
+		})
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	log.Printf("%s: parsed %d bodies", dataPath, len(requests))
+
+	return requests, nil
+}
+
+// AttachRequests sets request bodies and content types of answers
+// from ammo requests with the same method and uri.
+func AttachRequests(aa []*Answer, rr []*ammo.Request) {
+	requests := make(map[string]*ammo.Request, len(rr))
+	for _, r := range rr {
+		requests[r.Method+" "+r.URI] = r
+	}
+
+	attached := 0
+	for _, a := range aa {
+		r, exists := requests[a.Method+" "+a.URL.RequestURI()]
+		if !exists {
+			continue
+		}
+		a.Body = string(r.Body)
+		a.ContentType = r.Header.Get("Content-Type")
+		attached++
+	}
+
+	log.Printf("attached %d request bodies to %d answers", attached, len(aa))
+}
@@ -0,0 +1,94 @@
+package answers
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+func writeFile(t *testing.T, dir string, name string, data string) string {
+	path := filepath.Join(dir, name)
+	if err := ioutil.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestPOSTBodies(t *testing.T) {
+	type received struct {
+		contentType string
+		body        string
+	}
+	mu := sync.Mutex{}
+	requests := map[string]received{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		mu.Lock()
+		requests[r.Method+" "+r.URL.RequestURI()] = received{
+			contentType: r.Header.Get("Content-Type"),
+			body:        string(body),
+		}
+		mu.Unlock()
+
+		if r.Method == "POST" {
+			w.WriteHeader(http.StatusCreated)
+		}
+		w.Write([]byte("{}"))
+	}))
+	defer server.Close()
+
+	endpoint, err := url.Parse(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	aa, err := Parse(endpoint, writeFile(t, dir, "phase_2_post.answ", ""+
+		"POST	/accounts/new/?query_id=1	201	{}\n"+
+		"POST	/accounts/1/?query_id=2	201	{}\n"+
+		"GET	/accounts/filter/?query_id=3	200	{}\n",
+	))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rr, err := ParseBodies(writeFile(t, dir, "phase_2_post.body", ""+
+		"POST	/accounts/new/?query_id=1	{\"id\":1,\"email\":\"a@mail.ru\"}\n"+
+		"\n"+
+		"POST	/accounts/1/?query_id=2	{\"sname\":\"Иванов\"}\n",
+	))
+	if err != nil {
+		t.Fatal(err)
+	}
+	AttachRequests(aa, rr)
+
+	for _, a := range aa {
+		success, report, err := a.Test(server.Client())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !success {
+			t.Fatalf("%s %s: unexpected failure %+v", a.Method, a.URL, report)
+		}
+	}
+
+	for uri, expected := range map[string]received{
+		"POST /accounts/new/?query_id=1":   {"application/json", `{"id":1,"email":"a@mail.ru"}`},
+		"POST /accounts/1/?query_id=2":     {"application/json", `{"sname":"Иванов"}`},
+		"GET /accounts/filter/?query_id=3": {"", ""},
+	} {
+		if got, exists := requests[uri]; !exists || got != expected {
+			t.Fatalf("%s: expected %+v, got %+v", uri, expected, got)
+		}
+	}
+}
+
+func TestParseBodiesInvalid(t *testing.T) {
+	path := writeFile(t, t.TempDir(), "phase_2_post.body", "POST	/accounts/new/?query_id=1\n")
+	if _, err := ParseBodies(path); err == nil {
+		t.Fatal("expected an error for a line without a body")
+	}
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/ngalayko/highloadcup/app/ammo"
+	"github.com/ngalayko/highloadcup/tester/load"
+	"github.com/ngalayko/highloadcup/tester/stats"
+)
+
+// runLoad replays ammo file against the app with a load profile.
+func runLoad() {
+	profile, err := load.ParseProfile(*loadProfile)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	requests, err := ammo.Parse(*ammoPath)
+	if err != nil {
+		log.Fatal(err)
+	}
+	log.Printf("%s: parsed %d requests", *ammoPath, len(requests))
+
+	generator, err := load.New(*appEndpoint, requests, profile, *connections)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	result := generator.Run()
+	result.Print()
+
+	dir := filepath.Join(*logPath, "load")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		log.Fatal(err)
+	}
+
+	compareDir := ""
+	if *comparePath != "" {
+		compareDir = filepath.Join(*comparePath, "load")
+	}
+
+	regressions, err := stats.Report(
+		"load",
+		result.Latencies.Summary(result.Duration),
+		dir,
+		compareDir,
+		*threshold,
+	)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if len(regressions) != 0 {
+		os.Exit(1)
+	}
+}
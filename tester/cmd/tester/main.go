@@ -3,6 +3,10 @@ package main
 import (
 	"flag"
 	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
 
 	"github.com/ngalayko/highloadcup/tester"
 )
@@ -10,19 +14,96 @@ import (
 var (
 	appEndpoint         = flag.String("app_endpoint", "", "endpoint of the app")
 	dataPath            = flag.String("data_path", "", "path to a test file")
+	ammoPath            = flag.String("ammo_path", "", "path to an ammo file with request bodies")
 	logPath             = flag.String("log_path", "/tmp/tester/result.log", "path to write log")
 	healthCheckEndpoint = flag.String("healthcheck_endpoint", "", "application healthcheck endpoint")
+	healthCheckTimeout  = flag.Duration("healthcheck_timeout", 5*time.Minute, "time to wait for the application to start, 0 to wait forever")
+	minSuccessRate      = flag.Float64("min_success_rate", 0, "minimal success rate of every phase, from 0 to 1, to exit with zero status")
+	junitPath           = flag.String("junit_path", "", "path to write a JUnit XML report")
+	comparePath         = flag.String("compare_path", "", "log path of a previous run to compare latencies with")
+	threshold           = flag.Float64("regression_threshold", 0.1, "relative latency or throughput change reported as a regression, failing the run")
+	concurrency         = flag.Int("concurrency", 1, "number of answers tested concurrently within a phase")
+	mode                = flag.String("mode", "answers", "tester mode: answers to check answers, load to replay ammo with a load profile")
+	loadProfile         = flag.String("load_profile", "const(100,10s)", "load profile: list of const(rps, duration), line(from, to, duration) or step(from, to, step, duration)")
+	connections         = flag.Int("connections", 100, "number of keep-alive connections in load mode")
+	phases              = phasesFlag{}
 )
 
+func init() {
+	flag.Var(&phases, "phase", "test phase as name=answers_path[,ammo_path[,bodies_path]], can be repeated to run phases in order")
+}
+
+// phasesFlag is an ordered list of phases.
+type phasesFlag []*tester.PhaseConfig
+
+func (p *phasesFlag) String() string {
+	names := make([]string, 0, len(*p))
+	for _, phase := range *p {
+		names = append(names, phase.Name)
+	}
+	return strings.Join(names, ",")
+}
+
+func (p *phasesFlag) Set(v string) error {
+	phase, err := tester.ParsePhaseConfig(v)
+	if err != nil {
+		return err
+	}
+	*p = append(*p, phase)
+	return nil
+}
+
 func main() {
 	flag.Parse()
 
-	tester, err := tester.New(*appEndpoint, *dataPath)
+	if *mode == "load" {
+		runLoad()
+		return
+	}
+
+	if len(phases) == 0 {
+		phases = append(phases, &tester.PhaseConfig{
+			Name:     strings.TrimSuffix(filepath.Base(*dataPath), filepath.Ext(*dataPath)),
+			DataPath: *dataPath,
+			AmmoPath: *ammoPath,
+		})
+	}
+
+	t, err := tester.New(*appEndpoint, *concurrency, phases...)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	results, err := t.Run(
+		*healthCheckEndpoint,
+		*healthCheckTimeout,
+		*logPath,
+		*comparePath,
+		*threshold,
+	)
 	if err != nil {
-		log.Panic(err)
+		log.Fatal(err)
 	}
 
-	if err := tester.Run(*healthCheckEndpoint, *logPath); err != nil {
-		log.Panic(err)
+	if *junitPath != "" {
+		if err := tester.WriteJUnit(*junitPath, results); err != nil {
+			log.Fatal(err)
+		}
+		log.Printf("junit report is saved to: %s", *junitPath)
+	}
+
+	failed := false
+	for _, result := range results {
+		if result.SuccessRate() < *minSuccessRate {
+			log.Printf("%s: success rate %.2f%% is below %.2f%%", result.Name, result.SuccessRate()*100, *minSuccessRate*100)
+			failed = true
+		}
+		if len(result.Regressions) != 0 {
+			log.Printf("%s: %d latency or throughput regressions", result.Name, len(result.Regressions))
+			failed = true
+		}
+	}
+	if failed {
+		os.Exit(1)
 	}
 }
@@ -0,0 +1,98 @@
+package tester
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"os"
+	"sort"
+
+	"github.com/ngalayko/highloadcup/tester/answers"
+)
+
+type junitTestSuites struct {
+	XMLName xml.Name          `xml:"testsuites"`
+	Suites  []*junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name     string           `xml:"name,attr"`
+	Tests    int              `xml:"tests,attr"`
+	Failures int              `xml:"failures,attr"`
+	Time     float64          `xml:"time,attr"`
+	Cases    []*junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Time      float64       `xml:"time,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Text    string `xml:",chardata"`
+}
+
+// WriteJUnit writes phase results as a JUnit XML report.
+func WriteJUnit(path string, results []*PhaseResult) error {
+	report := &junitTestSuites{
+		Suites: make([]*junitTestSuite, 0, len(results)),
+	}
+
+	for _, result := range results {
+		suite := &junitTestSuite{
+			Name:     result.Name,
+			Tests:    result.Successful + result.Failed,
+			Failures: result.Failed,
+			Time:     result.Elapsed.Seconds(),
+		}
+
+		paths := make([]string, 0, len(result.Summaries))
+		for path := range result.Summaries {
+			paths = append(paths, path)
+		}
+		sort.Strings(paths)
+
+		for _, path := range paths {
+			for _, r := range result.Summaries[path].Reports {
+				testCase := &junitTestCase{
+					Name:      r.Method + " " + r.URL.RequestURI(),
+					ClassName: result.Name + "." + path,
+					Time:      r.ResponseTime.Seconds(),
+				}
+				if !r.Success {
+					details, err := json.MarshalIndent(struct {
+						Expected *answers.Answer `json:"expected"`
+						Got      *answers.Answer `json:"got"`
+						Diff     *answers.Diff   `json:"diff,omitempty"`
+					}{r.Expected, r.Got, r.Diff}, "", "	")
+					if err != nil {
+						return err
+					}
+					testCase.Failure = &junitFailure{
+						Message: "unexpected response",
+						Text:    string(details),
+					}
+				}
+				suite.Cases = append(suite.Cases, testCase)
+			}
+		}
+
+		report.Suites = append(report.Suites, suite)
+	}
+
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	if _, err := file.WriteString(xml.Header); err != nil {
+		return err
+	}
+
+	enc := xml.NewEncoder(file)
+	enc.Indent("", "	")
+	return enc.Encode(report)
+}
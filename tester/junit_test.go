@@ -0,0 +1,112 @@
+package tester
+
+import (
+	"encoding/xml"
+	"io/ioutil"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ngalayko/highloadcup/tester/answers"
+)
+
+func report(t *testing.T, method string, uri string, success bool) *answers.Report {
+	u, err := url.Parse("http://app" + uri)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := &answers.Report{
+		Expected:     &answers.Answer{},
+		Got:          &answers.Answer{},
+		URL:          u,
+		Method:       method,
+		Success:      success,
+		ResponseTime: 2 * time.Millisecond,
+	}
+	if !success {
+		r.Expected.StatusCode = 200
+		r.Got.StatusCode = 400
+	}
+	return r
+}
+
+func TestWriteJUnit(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "junit.xml")
+	results := []*PhaseResult{
+		{
+			Name:       "phase_1",
+			Successful: 2,
+			Failed:     1,
+			Elapsed:    time.Second,
+			Summaries: map[string]*Summary{
+				"GET_accounts_group_": {
+					Reports: []*answers.Report{
+						report(t, "GET", "/accounts/group/?keys=sex&query_id=2", true),
+					},
+				},
+				"GET_accounts_filter_": {
+					Reports: []*answers.Report{
+						report(t, "GET", "/accounts/filter/?sex_eq=m&query_id=1", true),
+						report(t, "GET", "/accounts/filter/?sex_eq=x&query_id=3", false),
+					},
+				},
+			},
+		},
+		{
+			Name:    "phase_2",
+			Elapsed: time.Second,
+		},
+	}
+	if err := WriteJUnit(path, results); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(string(data), xml.Header) {
+		t.Fatalf("expected an xml header, got %.40s", data)
+	}
+
+	got := &junitTestSuites{}
+	if err := xml.Unmarshal(data, got); err != nil {
+		t.Fatal(err)
+	}
+	if len(got.Suites) != 2 {
+		t.Fatalf("expected a suite per phase, got %d", len(got.Suites))
+	}
+
+	suite := got.Suites[0]
+	if suite.Name != "phase_1" || suite.Tests != 3 || suite.Failures != 1 || suite.Time != 1 {
+		t.Fatalf("unexpected suite %+v", suite)
+	}
+	// cases are ordered by path, then by reports order.
+	expected := []struct {
+		name      string
+		className string
+		failed    bool
+	}{
+		{"GET /accounts/filter/?sex_eq=m&query_id=1", "phase_1.GET_accounts_filter_", false},
+		{"GET /accounts/filter/?sex_eq=x&query_id=3", "phase_1.GET_accounts_filter_", true},
+		{"GET /accounts/group/?keys=sex&query_id=2", "phase_1.GET_accounts_group_", false},
+	}
+	if len(suite.Cases) != len(expected) {
+		t.Fatalf("expected %d cases, got %d", len(expected), len(suite.Cases))
+	}
+	for i, c := range expected {
+		got := suite.Cases[i]
+		if got.Name != c.name || got.ClassName != c.className || got.Time != 0.002 || (got.Failure != nil) != c.failed {
+			t.Fatalf("case %d: expected %+v, got %+v", i, c, got)
+		}
+	}
+	if failure := suite.Cases[1].Failure; failure.Message != "unexpected response" || !strings.Contains(failure.Text, `"StatusCode": 400`) {
+		t.Fatalf("unexpected failure %+v", failure)
+	}
+
+	if empty := got.Suites[1]; empty.Name != "phase_2" || empty.Tests != 0 || len(empty.Cases) != 0 {
+		t.Fatalf("unexpected empty suite %+v", empty)
+	}
+}
@@ -0,0 +1,180 @@
+package load
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/ngalayko/highloadcup/app/ammo"
+	"github.com/ngalayko/highloadcup/tester/stats"
+)
+
+// Generator replays ammo requests according to a load profile.
+type Generator struct {
+	endpoint    *url.URL
+	requests    []*ammo.Request
+	profile     Profile
+	connections int
+	client      *http.Client
+}
+
+// New is a load generator constructor.
+func New(
+	u string,
+	requests []*ammo.Request,
+	profile Profile,
+	connections int,
+) (*Generator, error) {
+	endpoint, err := url.Parse(u)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Generator{
+		endpoint:    endpoint,
+		requests:    requests,
+		profile:     profile,
+		connections: connections,
+		client: &http.Client{
+			Transport: &http.Transport{
+				MaxIdleConns:        connections,
+				MaxIdleConnsPerHost: connections,
+				MaxConnsPerHost:     connections,
+			},
+		},
+	}, nil
+}
+
+// Result is an aggregated result of a load run.
+type Result struct {
+	mu sync.Mutex
+
+	Sent        int
+	Errors      int
+	Dropped     int
+	StatusCodes map[int]int
+	Duration    time.Duration
+	Latencies   *stats.Collector
+}
+
+func (r *Result) add(req *ammo.Request, statusCode int, latency time.Duration, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.Sent++
+	if err != nil {
+		r.Errors++
+		return
+	}
+	r.StatusCodes[statusCode]++
+	r.Latencies.Add(stats.Class(req.Method, req.Path()), latency)
+}
+
+// shot is a request scheduled at a profile tick.
+type shot struct {
+	request   *ammo.Request
+	scheduled time.Time
+}
+
+// Run sends requests at the profile rate, looping over ammo if needed.
+// Requests scheduled while all connections are busy are dropped, latency
+// is measured from the schedule tick.
+func (g *Generator) Run() *Result {
+	result := &Result{
+		StatusCodes: map[int]int{},
+		Latencies:   stats.NewCollector(),
+	}
+	if len(g.requests) == 0 {
+		return result
+	}
+
+	// the queue is unbuffered, so a request is taken only by an idle connection.
+	queue := make(chan shot)
+	wg := &sync.WaitGroup{}
+	for i := 0; i < g.connections; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for s := range queue {
+				statusCode, err := g.send(s.request)
+				result.add(s.request, statusCode, time.Since(s.scheduled), err)
+			}
+		}()
+	}
+
+	log.Printf("starting load for %s with %d connections...", g.profile.Duration(), g.connections)
+
+	start := time.Now()
+	last := start
+	budget := 0.0
+	next := 0
+
+	ticker := time.NewTicker(time.Millisecond)
+	for now := range ticker.C {
+		elapsed := now.Sub(start)
+		if elapsed >= g.profile.Duration() {
+			break
+		}
+
+		budget += g.profile.RPS(elapsed) * now.Sub(last).Seconds()
+		last = now
+
+		for ; budget >= 1; budget-- {
+			select {
+			case queue <- shot{request: g.requests[next%len(g.requests)], scheduled: now}:
+				next++
+			default:
+				result.mu.Lock()
+				result.Dropped++
+				result.mu.Unlock()
+			}
+		}
+	}
+	ticker.Stop()
+	close(queue)
+	wg.Wait()
+
+	result.Duration = time.Since(start)
+	return result
+}
+
+func (g *Generator) send(r *ammo.Request) (int, error) {
+	req, err := http.NewRequest(r.Method, g.endpoint.String()+r.URI, bytes.NewReader(r.Body))
+	if err != nil {
+		return 0, err
+	}
+	for key, values := range r.Header {
+		for _, value := range values {
+			req.Header.Add(key, value)
+		}
+	}
+
+	resp, err := g.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	// read the body till the end to reuse keep-alive connection.
+	if _, err := io.Copy(ioutil.Discard, resp.Body); err != nil {
+		return 0, err
+	}
+	return resp.StatusCode, nil
+}
+
+// Print logs the result.
+func (r *Result) Print() {
+	log.Printf("duration: %s", r.Duration)
+	log.Printf("sent: %d", r.Sent)
+	log.Printf("errors: %d", r.Errors)
+	log.Printf("dropped: %d", r.Dropped)
+	log.Printf("rps: %.2f", float64(r.Sent)/r.Duration.Seconds())
+	for code, count := range r.StatusCodes {
+		log.Printf("status %d: %d", code, count)
+	}
+}
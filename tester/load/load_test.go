@@ -0,0 +1,38 @@
+package load
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/ngalayko/highloadcup/app/ammo"
+)
+
+func TestRunDropsWhenBusy(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(50 * time.Millisecond)
+	}))
+	defer server.Close()
+
+	p, err := ParseProfile("const(1000, 200ms)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	g, err := New(server.URL, []*ammo.Request{{Method: "GET", URI: "/accounts/filter/"}}, p, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := g.Run()
+	// a single connection sends about one request per 50ms.
+	if r.Sent == 0 || r.Sent > 10 {
+		t.Fatalf("expected a few requests sent by a busy connection, got %d", r.Sent)
+	}
+	if r.Dropped < 100 {
+		t.Fatalf("expected requests scheduled while the connection is busy to be dropped, got %d", r.Dropped)
+	}
+	if r.Errors != 0 || r.StatusCodes[http.StatusOK] != r.Sent {
+		t.Fatalf("expected all sent requests to succeed, got %d errors and %v", r.Errors, r.StatusCodes)
+	}
+}
@@ -0,0 +1,163 @@
+package load
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Profile is a load profile.
+type Profile interface {
+	// RPS returns target requests per second after elapsed time.
+	RPS(elapsed time.Duration) float64
+	// Duration returns total profile duration.
+	Duration() time.Duration
+}
+
+type constProfile struct {
+	rps      float64
+	duration time.Duration
+}
+
+func (p *constProfile) RPS(time.Duration) float64 {
+	return p.rps
+}
+
+func (p *constProfile) Duration() time.Duration {
+	return p.duration
+}
+
+type lineProfile struct {
+	from     float64
+	to       float64
+	duration time.Duration
+}
+
+func (p *lineProfile) RPS(elapsed time.Duration) float64 {
+	return p.from + (p.to-p.from)*elapsed.Seconds()/p.duration.Seconds()
+}
+
+func (p *lineProfile) Duration() time.Duration {
+	return p.duration
+}
+
+type stepProfile struct {
+	from         float64
+	to           float64
+	step         float64
+	stepDuration time.Duration
+}
+
+func (p *stepProfile) RPS(elapsed time.Duration) float64 {
+	steps := float64(elapsed / p.stepDuration)
+	if p.to < p.from {
+		return p.from - steps*p.step
+	}
+	return p.from + steps*p.step
+}
+
+func (p *stepProfile) Duration() time.Duration {
+	diff := p.to - p.from
+	if diff < 0 {
+		diff = -diff
+	}
+	return time.Duration(diff/p.step+1) * p.stepDuration
+}
+
+// sequence runs profiles one after another.
+type sequence []Profile
+
+func (s sequence) RPS(elapsed time.Duration) float64 {
+	for _, p := range s {
+		if elapsed < p.Duration() {
+			return p.RPS(elapsed)
+		}
+		elapsed -= p.Duration()
+	}
+	return 0
+}
+
+func (s sequence) Duration() time.Duration {
+	var total time.Duration
+	for _, p := range s {
+		total += p.Duration()
+	}
+	return total
+}
+
+var (
+	scheduleRegexp = regexp.MustCompile(`[^()]*\([^()]*\)`)
+	profileRegexp  = regexp.MustCompile(`^(const|line|step)\s*\(([^)]*)\)$`)
+)
+
+// ParseProfile parses a list of Yandex.Tank style schedules:
+// const(rps, duration), line(from, to, duration) and step(from, to, step, duration).
+// Schedules end with a closing parenthesis, so arguments may contain spaces.
+func ParseProfile(s string) (Profile, error) {
+	if rest := strings.TrimSpace(scheduleRegexp.ReplaceAllString(s, "")); rest != "" {
+		return nil, fmt.Errorf("invalid schedule '%s'", rest)
+	}
+
+	seq := sequence{}
+	for _, part := range scheduleRegexp.FindAllString(s, -1) {
+		p, err := parseSchedule(strings.TrimSpace(part))
+		if err != nil {
+			return nil, err
+		}
+		seq = append(seq, p)
+	}
+	if len(seq) == 0 {
+		return nil, fmt.Errorf("empty load profile")
+	}
+	return seq, nil
+}
+
+func parseSchedule(s string) (Profile, error) {
+	match := profileRegexp.FindStringSubmatch(s)
+	if match == nil {
+		return nil, fmt.Errorf("invalid schedule '%s'", s)
+	}
+
+	args := strings.Split(match[2], ",")
+	duration, err := time.ParseDuration(strings.TrimSpace(args[len(args)-1]))
+	if err != nil {
+		return nil, fmt.Errorf("invalid schedule '%s': %s", s, err)
+	}
+	if duration <= 0 {
+		return nil, fmt.Errorf("invalid schedule '%s': duration must be positive", s)
+	}
+
+	values := make([]float64, 0, len(args)-1)
+	for _, arg := range args[:len(args)-1] {
+		v, err := strconv.ParseFloat(strings.TrimSpace(arg), 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid schedule '%s': %s", s, err)
+		}
+		values = append(values, v)
+	}
+
+	switch {
+	case match[1] == "const" && len(values) == 1:
+		return &constProfile{
+			rps:      values[0],
+			duration: duration,
+		}, nil
+	case match[1] == "line" && len(values) == 2:
+		return &lineProfile{
+			from:     values[0],
+			to:       values[1],
+			duration: duration,
+		}, nil
+	case match[1] == "step" && len(values) == 3 && values[2] > 0:
+		return &stepProfile{
+			from:         values[0],
+			to:           values[1],
+			step:         values[2],
+			stepDuration: duration,
+		}, nil
+	default:
+		return nil, fmt.Errorf("invalid schedule '%s': wrong arguments", s)
+	}
+}
@@ -0,0 +1,111 @@
+package load
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestProfileRPS(t *testing.T) {
+	type point struct {
+		elapsed time.Duration
+		rps     float64
+	}
+	for _, c := range []struct {
+		profile  string
+		duration time.Duration
+		points   []point
+	}{
+		{
+			profile:  "const(100, 10s)",
+			duration: 10 * time.Second,
+			points: []point{
+				{0, 100},
+				{5 * time.Second, 100},
+				{9999 * time.Millisecond, 100},
+			},
+		},
+		{
+			profile:  "line(0, 100, 10s)",
+			duration: 10 * time.Second,
+			points: []point{
+				{0, 0},
+				{2500 * time.Millisecond, 25},
+				{5 * time.Second, 50},
+				{9 * time.Second, 90},
+			},
+		},
+		{
+			profile:  "line(100, 0, 4s)",
+			duration: 4 * time.Second,
+			points: []point{
+				{0, 100},
+				{time.Second, 75},
+				{3 * time.Second, 25},
+			},
+		},
+		{
+			profile:  "step(10, 30, 10, 5s)",
+			duration: 15 * time.Second,
+			points: []point{
+				{0, 10},
+				{4900 * time.Millisecond, 10},
+				{5 * time.Second, 20},
+				{10 * time.Second, 30},
+				{14900 * time.Millisecond, 30},
+			},
+		},
+		{
+			profile:  "step(30, 10, 10, 5s)",
+			duration: 15 * time.Second,
+			points: []point{
+				{0, 30},
+				{5 * time.Second, 20},
+				{10 * time.Second, 10},
+			},
+		},
+		{
+			profile:  "const(10, 1s) line(10, 20, 2s) step(5, 15, 5, 1s)",
+			duration: 6 * time.Second,
+			points: []point{
+				{500 * time.Millisecond, 10},
+				{time.Second, 10},
+				{2 * time.Second, 15},
+				{3 * time.Second, 5},
+				{4 * time.Second, 10},
+				{5 * time.Second, 15},
+				{6 * time.Second, 0},
+			},
+		},
+	} {
+		p, err := ParseProfile(c.profile)
+		if err != nil {
+			t.Fatalf("%s: %s", c.profile, err)
+		}
+		if p.Duration() != c.duration {
+			t.Fatalf("%s: expected duration %s, got %s", c.profile, c.duration, p.Duration())
+		}
+		for _, point := range c.points {
+			if rps := p.RPS(point.elapsed); math.Abs(rps-point.rps) > 1e-9 {
+				t.Fatalf("%s: expected %.2f rps after %s, got %.2f", c.profile, point.rps, point.elapsed, rps)
+			}
+		}
+	}
+}
+
+func TestParseProfileInvalid(t *testing.T) {
+	for _, s := range []string{
+		"",
+		"const(10)",
+		"const(10, 0s)",
+		"const(ten, 1s)",
+		"line(1, 1s)",
+		"step(1, 2, 0, 1s)",
+		"foo(1, 1s)",
+		"const(1, 1s) rest",
+	} {
+		if _, err := ParseProfile(s); err == nil {
+			t.Fatalf("%s: expected an error", s)
+		}
+	}
+}
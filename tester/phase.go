@@ -0,0 +1,77 @@
+package tester
+
+import (
+	"fmt"
+	"log"
+	"net/url"
+	"strings"
+
+	"github.com/ngalayko/highloadcup/app/ammo"
+	"github.com/ngalayko/highloadcup/tester/answers"
+)
+
+// PhaseConfig describes files of a test phase.
+type PhaseConfig struct {
+	Name     string
+	DataPath string
+	AmmoPath string
+	// BodiesPath is a companion file of answer request bodies.
+	BodiesPath string
+}
+
+// ParsePhaseConfig parses phase config from
+// "name=answers_path[,ammo_path[,bodies_path]]", ammo_path may be empty.
+func ParsePhaseConfig(s string) (*PhaseConfig, error) {
+	parts := strings.SplitN(s, "=", 2)
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return nil, fmt.Errorf("invalid phase '%s', expected name=answers_path[,ammo_path[,bodies_path]]", s)
+	}
+
+	paths := strings.SplitN(parts[1], ",", 3)
+	cfg := &PhaseConfig{
+		Name:     parts[0],
+		DataPath: paths[0],
+	}
+	if len(paths) > 1 {
+		cfg.AmmoPath = paths[1]
+	}
+	if len(paths) > 2 {
+		cfg.BodiesPath = paths[2]
+	}
+	return cfg, nil
+}
+
+// Phase is a named set of answers tested together.
+type Phase struct {
+	Name    string
+	answers []*answers.Answer
+}
+
+func newPhase(endpoint *url.URL, cfg *PhaseConfig) (*Phase, error) {
+	aa, err := answers.Parse(endpoint, cfg.DataPath)
+	if err != nil {
+		return nil, err
+	}
+
+	if cfg.AmmoPath != "" {
+		rr, err := ammo.Parse(cfg.AmmoPath)
+		if err != nil {
+			return nil, err
+		}
+		log.Printf("%s: parsed %d requests", cfg.AmmoPath, len(rr))
+		answers.AttachRequests(aa, rr)
+	}
+
+	if cfg.BodiesPath != "" {
+		rr, err := answers.ParseBodies(cfg.BodiesPath)
+		if err != nil {
+			return nil, err
+		}
+		answers.AttachRequests(aa, rr)
+	}
+
+	return &Phase{
+		Name:    cfg.Name,
+		answers: aa,
+	}, nil
+}
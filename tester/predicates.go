@@ -0,0 +1,94 @@
+package tester
+
+import (
+	"log"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// valuedParams are query parameters which values change the query kind.
+var valuedParams = map[string]bool{
+	"keys":  true,
+	"order": true,
+}
+
+// PredicateSummary is an aggregation of reports with the same set of predicates.
+type PredicateSummary struct {
+	Successful  float64
+	Failed      float64
+	SuccessRate float64
+}
+
+// predicates returns a sorted set of query predicates, e.g. limit+sname_starts.
+func predicates(u *url.URL) string {
+	query := u.Query()
+
+	parts := make([]string, 0, len(query))
+	for key, values := range query {
+		if key == "query_id" {
+			continue
+		}
+		if valuedParams[key] && len(values) != 0 {
+			key = key + "=" + values[0]
+		}
+		parts = append(parts, key)
+	}
+	sort.Strings(parts)
+
+	if len(parts) == 0 {
+		return "none"
+	}
+	return strings.Join(parts, "+")
+}
+
+// add adds a report result to a predicates summary.
+func (s *Summary) add(u *url.URL, ok bool) {
+	if s.Predicates == nil {
+		s.Predicates = map[string]*PredicateSummary{}
+	}
+
+	key := predicates(u)
+	ps, exists := s.Predicates[key]
+	if !exists {
+		ps = &PredicateSummary{}
+		s.Predicates[key] = ps
+	}
+
+	if ok {
+		ps.Successful++
+	} else {
+		ps.Failed++
+	}
+}
+
+// logFailedPredicates logs predicate sets with failures, worst first.
+func (s *Summary) logFailedPredicates(prefix string) {
+	keys := make([]string, 0, len(s.Predicates))
+	for key, ps := range s.Predicates {
+		ps.SuccessRate = rate(ps.Successful, ps.Failed)
+		if ps.Failed == 0 {
+			continue
+		}
+		keys = append(keys, key)
+	}
+
+	sort.Slice(keys, func(i, j int) bool {
+		pi, pj := s.Predicates[keys[i]], s.Predicates[keys[j]]
+		if pi.SuccessRate == pj.SuccessRate {
+			return keys[i] < keys[j]
+		}
+		return pi.SuccessRate < pj.SuccessRate
+	})
+
+	for _, key := range keys {
+		ps := s.Predicates[key]
+		log.Printf("%s %s: failed %.0f of %.0f, success rate: %.2f%%",
+			prefix,
+			key,
+			ps.Failed,
+			ps.Successful+ps.Failed,
+			ps.SuccessRate*100,
+		)
+	}
+}
@@ -0,0 +1,61 @@
+package tester
+
+import (
+	"io/ioutil"
+	"log"
+	"net/url"
+	"reflect"
+	"testing"
+)
+
+func TestPredicates(t *testing.T) {
+	for uri, expected := range map[string]string{
+		"/accounts/filter/?query_id=1":                                        "none",
+		"/accounts/filter/?query_id=1&limit=10":                               "limit",
+		"/accounts/filter/?sname_starts=%D0%98&limit=10&query_id=1":           "limit+sname_starts",
+		"/accounts/filter/?limit=10&sname_starts=%D0%9F&sex_eq=m":             "limit+sex_eq+sname_starts",
+		"/accounts/group/?keys=sex&order=1&limit=5&query_id=2":                "keys=sex+limit+order=1",
+		"/accounts/group/?keys=city,status&order=-1&limit=5&birth=1990":       "birth+keys=city,status+limit+order=-1",
+		"/accounts/1/recommend/?country=%D0%A0%D0%BE%D1%81%D1%81%D0%B8%D1%8F": "country",
+	} {
+		u, err := url.Parse(uri)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := predicates(u); got != expected {
+			t.Fatalf("%s: expected predicates %s, got %s", uri, expected, got)
+		}
+	}
+}
+
+func TestSummaryPredicates(t *testing.T) {
+	log.SetOutput(ioutil.Discard)
+
+	s := &Summary{}
+	for _, c := range []struct {
+		uri string
+		ok  bool
+	}{
+		{"/accounts/filter/?sex_eq=m&limit=1&query_id=1", true},
+		{"/accounts/filter/?limit=2&sex_eq=f&query_id=2", false},
+		{"/accounts/filter/?sex_eq=m&limit=3&query_id=3", true},
+		{"/accounts/filter/?city_eq=A&limit=1&query_id=4", false},
+		{"/accounts/filter/?limit=1&query_id=5", true},
+	} {
+		u, err := url.Parse(c.uri)
+		if err != nil {
+			t.Fatal(err)
+		}
+		s.add(u, c.ok)
+	}
+	s.logFailedPredicates("test")
+
+	expected := map[string]*PredicateSummary{
+		"limit+sex_eq":  {Successful: 2, Failed: 1, SuccessRate: 2.0 / 3},
+		"city_eq+limit": {Failed: 1, SuccessRate: 0},
+		"limit":         {Successful: 1, SuccessRate: 1},
+	}
+	if !reflect.DeepEqual(s.Predicates, expected) {
+		t.Fatalf("unexpected predicate buckets %+v", s.Predicates)
+	}
+}
@@ -0,0 +1,276 @@
+package stats
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"math"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Endpoint classes.
+const (
+	ClassFilter    = "filter"
+	ClassGroup     = "group"
+	ClassRecommend = "recommend"
+	ClassSuggest   = "suggest"
+	ClassNew       = "new"
+	ClassUpdate    = "update"
+	ClassLikes     = "likes"
+	ClassOther     = "other"
+)
+
+var (
+	recommendPath = regexp.MustCompile(`^/accounts/\d+/recommend/$`)
+	suggestPath   = regexp.MustCompile(`^/accounts/\d+/suggest/$`)
+	updatePath    = regexp.MustCompile(`^/accounts/\d+/$`)
+)
+
+// Class returns endpoint class of a request.
+func Class(method string, path string) string {
+	switch {
+	case method == "GET" && path == "/accounts/filter/":
+		return ClassFilter
+	case method == "GET" && path == "/accounts/group/":
+		return ClassGroup
+	case method == "GET" && recommendPath.MatchString(path):
+		return ClassRecommend
+	case method == "GET" && suggestPath.MatchString(path):
+		return ClassSuggest
+	case method == "POST" && path == "/accounts/new/":
+		return ClassNew
+	case method == "POST" && path == "/accounts/likes/":
+		return ClassLikes
+	case method == "POST" && updatePath.MatchString(path):
+		return ClassUpdate
+	default:
+		return ClassOther
+	}
+}
+
+// Collector collects latencies per endpoint class.
+type Collector struct {
+	mu        sync.Mutex
+	latencies map[string][]time.Duration
+}
+
+// NewCollector is a collector constructor.
+func NewCollector() *Collector {
+	return &Collector{
+		latencies: map[string][]time.Duration{},
+	}
+}
+
+// Add adds a request latency.
+func (c *Collector) Add(class string, latency time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.latencies[class] = append(c.latencies[class], latency)
+}
+
+// ClassSummary is a latency and throughput summary of an endpoint class.
+type ClassSummary struct {
+	Count int     `json:"count"`
+	RPS   float64 `json:"rps"`
+	P50   float64 `json:"p50_ms"`
+	P90   float64 `json:"p90_ms"`
+	P99   float64 `json:"p99_ms"`
+	Max   float64 `json:"max_ms"`
+}
+
+// Summary is a summary of all endpoint classes.
+type Summary map[string]*ClassSummary
+
+// Summary returns summary of collected latencies for a run that took elapsed time.
+func (c *Collector) Summary(elapsed time.Duration) Summary {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	summary := make(Summary, len(c.latencies))
+	for class, latencies := range c.latencies {
+		sorted := make([]time.Duration, len(latencies))
+		copy(sorted, latencies)
+		sort.Slice(sorted, func(i, j int) bool {
+			return sorted[i] < sorted[j]
+		})
+
+		summary[class] = &ClassSummary{
+			Count: len(sorted),
+			RPS:   float64(len(sorted)) / elapsed.Seconds(),
+			P50:   milliseconds(percentile(sorted, 0.5)),
+			P90:   milliseconds(percentile(sorted, 0.9)),
+			P99:   milliseconds(percentile(sorted, 0.99)),
+			Max:   milliseconds(sorted[len(sorted)-1]),
+		}
+	}
+	return summary
+}
+
+func percentile(sorted []time.Duration, q float64) time.Duration {
+	i := int(math.Ceil(q*float64(len(sorted)))) - 1
+	if i < 0 {
+		i = 0
+	}
+	return sorted[i]
+}
+
+func milliseconds(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+// Classes returns summary classes in alphabetical order.
+func (s Summary) Classes() []string {
+	classes := make([]string, 0, len(s))
+	for class := range s {
+		classes = append(classes, class)
+	}
+	sort.Strings(classes)
+	return classes
+}
+
+// Lines returns human readable summary lines.
+func (s Summary) Lines() []string {
+	lines := make([]string, 0, len(s))
+	for _, class := range s.Classes() {
+		c := s[class]
+		lines = append(lines, fmt.Sprintf(
+			"%s: count %d, rps %.2f, p50 %.3fms, p90 %.3fms, p99 %.3fms, max %.3fms",
+			class, c.Count, c.RPS, c.P50, c.P90, c.P99, c.Max,
+		))
+	}
+	return lines
+}
+
+// Write writes summary as json to a file.
+func (s Summary) Write(path string) error {
+	data, err := json.MarshalIndent(s, "", "	")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, data, 0644)
+}
+
+// Read reads summary from a json file.
+func Read(path string) (Summary, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	s := Summary{}
+	return s, json.Unmarshal(data, &s)
+}
+
+// Regression is a metric of an endpoint class which latency grew or
+// throughput fell more than a threshold.
+type Regression struct {
+	Class  string
+	Metric string
+	Prev   float64
+	Cur    float64
+	Change float64
+}
+
+// String returns a human readable regression.
+func (r *Regression) String() string {
+	return fmt.Sprintf("%s %s: %.3f -> %.3f (%+.1f%%)", r.Class, r.Metric, r.Prev, r.Cur, r.Change*100)
+}
+
+// Compare returns lines describing changes from a previous summary and
+// regressions, metrics which latency grew or throughput fell more than
+// threshold.
+func Compare(prev Summary, cur Summary, threshold float64) ([]string, []*Regression) {
+	lines := []string{}
+	regressions := []*Regression{}
+
+	for _, class := range cur.Classes() {
+		p, exists := prev[class]
+		if !exists {
+			continue
+		}
+		c := cur[class]
+
+		metrics := []struct {
+			name    string
+			prev    float64
+			cur     float64
+			inverse bool
+		}{
+			{"rps", p.RPS, c.RPS, true},
+			{"p50", p.P50, c.P50, false},
+			{"p90", p.P90, c.P90, false},
+			{"p99", p.P99, c.P99, false},
+			{"max", p.Max, c.Max, false},
+		}
+		for _, m := range metrics {
+			if m.prev == 0 {
+				continue
+			}
+			r := &Regression{
+				Class:  class,
+				Metric: m.name,
+				Prev:   m.prev,
+				Cur:    m.cur,
+				Change: (m.cur - m.prev) / m.prev,
+			}
+			line := r.String()
+			if (!m.inverse && r.Change > threshold) || (m.inverse && -r.Change > threshold) {
+				line += " REGRESSION"
+				regressions = append(regressions, r)
+			}
+			lines = append(lines, line)
+		}
+	}
+	return lines, regressions
+}
+
+// summaryFile is a name of a summary file in a run directory.
+const summaryFile = "summary.json"
+
+// Report logs summary of a run, writes it to dir and compares it
+// with a summary of a previous run in compareDir if it is not empty,
+// returning regressions.
+func Report(
+	name string,
+	s Summary,
+	dir string,
+	compareDir string,
+	threshold float64,
+) ([]*Regression, error) {
+	for _, line := range s.Lines() {
+		log.Printf("%s: %s", name, line)
+	}
+
+	if err := s.Write(filepath.Join(dir, summaryFile)); err != nil {
+		return nil, err
+	}
+
+	if compareDir == "" {
+		return nil, nil
+	}
+
+	prev, err := Read(filepath.Join(compareDir, summaryFile))
+	if os.IsNotExist(err) {
+		log.Printf("%s: no previous summary in %s", name, compareDir)
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	lines, regressions := Compare(prev, s, threshold)
+	for _, line := range lines {
+		log.Printf("%s: %s", name, line)
+	}
+	if len(regressions) != 0 {
+		log.Printf("%s: performance regressed more than %.1f%%", name, threshold*100)
+	}
+	return regressions, nil
+}
@@ -0,0 +1,105 @@
+package stats
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestSummaryPercentiles(t *testing.T) {
+	for _, c := range []struct {
+		name      string
+		latencies []time.Duration
+		expected  *ClassSummary
+	}{
+		{
+			name:      "single",
+			latencies: []time.Duration{3 * time.Millisecond},
+			expected:  &ClassSummary{Count: 1, RPS: 0.5, P50: 3, P90: 3, P99: 3, Max: 3},
+		},
+		{
+			name:      "unsorted",
+			latencies: []time.Duration{4 * time.Millisecond, time.Millisecond, 3 * time.Millisecond, 2 * time.Millisecond},
+			expected:  &ClassSummary{Count: 4, RPS: 2, P50: 2, P90: 4, P99: 4, Max: 4},
+		},
+		{
+			name: "hundred",
+			latencies: func() []time.Duration {
+				dd := []time.Duration{}
+				for i := 100; i > 0; i-- {
+					dd = append(dd, time.Duration(i)*time.Millisecond)
+				}
+				return dd
+			}(),
+			expected: &ClassSummary{Count: 100, RPS: 50, P50: 50, P90: 90, P99: 99, Max: 100},
+		},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			collector := NewCollector()
+			for _, latency := range c.latencies {
+				collector.Add(ClassFilter, latency)
+			}
+
+			summary := collector.Summary(2 * time.Second)
+			if len(summary) != 1 {
+				t.Fatalf("expected a single class, got %v", summary.Classes())
+			}
+			if got := summary[ClassFilter]; !reflect.DeepEqual(got, c.expected) {
+				t.Fatalf("expected %+v, got %+v", c.expected, got)
+			}
+		})
+	}
+}
+
+func TestClass(t *testing.T) {
+	for _, c := range []struct {
+		method string
+		path   string
+		class  string
+	}{
+		{"GET", "/accounts/filter/", ClassFilter},
+		{"GET", "/accounts/group/", ClassGroup},
+		{"GET", "/accounts/123/recommend/", ClassRecommend},
+		{"GET", "/accounts/123/suggest/", ClassSuggest},
+		{"POST", "/accounts/new/", ClassNew},
+		{"POST", "/accounts/likes/", ClassLikes},
+		{"POST", "/accounts/123/", ClassUpdate},
+		{"GET", "/accounts/123/", ClassOther},
+		{"POST", "/accounts/filter/", ClassOther},
+	} {
+		if class := Class(c.method, c.path); class != c.class {
+			t.Fatalf("%s %s: expected %s, got %s", c.method, c.path, c.class, class)
+		}
+	}
+}
+
+func TestCompare(t *testing.T) {
+	prev := Summary{
+		ClassFilter: {Count: 10, RPS: 100, P50: 1, P90: 2, P99: 4, Max: 10},
+		ClassGroup:  {Count: 10, RPS: 100, P50: 1, P90: 2, P99: 4, Max: 10},
+	}
+	cur := Summary{
+		// throughput fell and p99 grew more than the threshold.
+		ClassFilter: {Count: 10, RPS: 80, P50: 1.05, P90: 2, P99: 5, Max: 10},
+		// throughput grew and latencies fell.
+		ClassGroup: {Count: 10, RPS: 200, P50: 0.5, P90: 1, P99: 2, Max: 5},
+		// a new class is not compared.
+		ClassLikes: {Count: 10, RPS: 1, P50: 100, P90: 100, P99: 100, Max: 100},
+	}
+
+	lines, regressions := Compare(prev, cur, 0.1)
+	if len(lines) != 10 {
+		t.Fatalf("expected 10 compared metrics, got %v", lines)
+	}
+	expected := []*Regression{
+		{Class: ClassFilter, Metric: "rps", Prev: 100, Cur: 80, Change: -0.2},
+		{Class: ClassFilter, Metric: "p99", Prev: 4, Cur: 5, Change: 0.25},
+	}
+	if !reflect.DeepEqual(regressions, expected) {
+		t.Fatalf("expected regressions %v, got %v", expected, regressions)
+	}
+
+	if _, regressions := Compare(prev, prev, 0.1); len(regressions) != 0 {
+		t.Fatalf("expected no regressions of the same summary, got %v", regressions)
+	}
+}
@@ -7,36 +7,58 @@ import (
 	"net/http"
 	"net/url"
 	"os"
+	"path/filepath"
 	"regexp"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/ngalayko/highloadcup/tester/answers"
+	"github.com/ngalayko/highloadcup/tester/stats"
 )
 
 // Tester runs tests for the app.
 type Tester struct {
-	answers []*answers.Answer
+	phases      []*Phase
+	concurrency int
+	client      *http.Client
 }
 
 // New is a tester constructor.
+// Phases are tested in the given order against the same app,
+// answers of a phase are tested by concurrency workers.
 func New(
 	u string,
-	dataPath string,
+	concurrency int,
+	phases ...*PhaseConfig,
 ) (*Tester, error) {
 	endpoint, err := url.Parse(u)
 	if err != nil {
 		return nil, err
 	}
 
-	aa, err := answers.Parse(endpoint, dataPath)
-	if err != nil {
-		return nil, err
+	if concurrency < 1 {
+		concurrency = 1
 	}
 
-	return &Tester{
-		answers: aa,
-	}, nil
+	t := &Tester{
+		phases:      make([]*Phase, 0, len(phases)),
+		concurrency: concurrency,
+		client: &http.Client{
+			Transport: &http.Transport{
+				MaxIdleConns:        concurrency,
+				MaxIdleConnsPerHost: concurrency,
+			},
+		},
+	}
+	for _, cfg := range phases {
+		phase, err := newPhase(endpoint, cfg)
+		if err != nil {
+			return nil, fmt.Errorf("can't parse phase %s: %s", cfg.Name, err)
+		}
+		t.phases = append(t.phases, phase)
+	}
+	return t, nil
 }
 
 var digits = regexp.MustCompile(`\d+`)
@@ -46,28 +68,86 @@ type Summary struct {
 	Successful  float64
 	Failed      float64
 	SuccessRate float64
+	Predicates  map[string]*PredicateSummary `json:",omitempty"`
 	Reports     []*answers.Report
 }
 
-// Run runs tests.
+// PhaseResult is a result of a phase run.
+type PhaseResult struct {
+	Name       string
+	Successful int
+	Failed     int
+	Elapsed    time.Duration
+	Summaries  map[string]*Summary
+	// Regressions are latency and throughput regressions compared
+	// with a previous run.
+	Regressions []*stats.Regression
+}
+
+// SuccessRate returns a share of successful answers.
+func (r *PhaseResult) SuccessRate() float64 {
+	return rate(float64(r.Successful), float64(r.Failed))
+}
+
+// Run runs tests after the app healthcheck succeeds or fails with an error
+// after healthcheckTimeout, if it is not zero.
+// If comparePath is not empty, phase latencies are compared with a previous run
+// logged there, and changes larger than threshold are reported as regressions.
 func (t *Tester) Run(
 	healthcheckEndpoint string,
+	healthcheckTimeout time.Duration,
 	logPath string,
-) error {
-	<-waitHealthcheck(healthcheckEndpoint)
+	comparePath string,
+	threshold float64,
+) ([]*PhaseResult, error) {
+	if err := waitHealthcheck(healthcheckEndpoint, healthcheckTimeout); err != nil {
+		return nil, err
+	}
 
-	log.Printf("starting tests...")
+	results := make([]*PhaseResult, 0, len(t.phases))
+	for _, phase := range t.phases {
+		compareDir := ""
+		if comparePath != "" {
+			compareDir = filepath.Join(comparePath, phase.Name)
+		}
+		result, err := t.runPhase(phase, filepath.Join(logPath, phase.Name), compareDir, threshold)
+		if err != nil {
+			return nil, fmt.Errorf("phase %s: %s", phase.Name, err)
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+func (t *Tester) runPhase(
+	phase *Phase,
+	logPath string,
+	compareDir string,
+	threshold float64,
+) (*PhaseResult, error) {
+	log.Printf("starting %s tests...", phase.Name)
+
+	if err := os.MkdirAll(logPath, 0755); err != nil {
+		return nil, err
+	}
 
 	reports := map[string]*Summary{}
+	latencies := stats.NewCollector()
+
+	start := time.Now()
+	results := t.test(phase.answers)
+	elapsed := time.Since(start)
 
 	successful := 0
-	for _, a := range t.answers {
-		ok, report, err := a.Test()
-		if err != nil {
-			return err
+	for _, result := range results {
+		ok, report := result.ok, result.report
+
+		// requests failed to be sent have no latency.
+		if result.err == nil {
+			latencies.Add(stats.Class(report.Method, report.URL.Path), report.ResponseTime)
 		}
 
-		path := report.URL.Path
+		path := report.Method + report.URL.Path
 		path = strings.Replace(path, "/", "_", -1)
 		path = digits.ReplaceAllString(path, "")
 
@@ -83,25 +163,97 @@ func (t *Tester) Run(
 		} else {
 			summary.Failed++
 		}
+		summary.add(report.URL, ok)
 
 		reports[path].Reports = append(reports[path].Reports, report)
 	}
 
 	for path, report := range reports {
-		report.SuccessRate = report.Successful / (report.Successful + report.Failed)
+		report.SuccessRate = rate(report.Successful, report.Failed)
+		report.logFailedPredicates(phase.Name + ": " + path)
 		if err := writeToFile(
 			fmt.Sprintf("%s/%s.log", logPath, path),
 			report,
 		); err != nil {
-			return err
+			return nil, err
 		}
 	}
 
-	log.Printf("logs are saved to: %s", logPath)
-	log.Printf("successful: %d", successful)
-	log.Printf("failed: %d", len(t.answers)-successful)
-	log.Printf("success rate: %.2f%%", float64(successful)/float64(len(t.answers))*100)
-	return nil
+	result := &PhaseResult{
+		Name:       phase.Name,
+		Successful: successful,
+		Failed:     len(phase.answers) - successful,
+		Elapsed:    elapsed,
+		Summaries:  reports,
+	}
+
+	log.Printf("%s: logs are saved to: %s", phase.Name, logPath)
+	log.Printf("%s: successful: %d", phase.Name, result.Successful)
+	log.Printf("%s: failed: %d", phase.Name, result.Failed)
+	log.Printf("%s: success rate: %.2f%%", phase.Name, result.SuccessRate()*100)
+
+	regressions, err := stats.Report(phase.Name, latencies.Summary(elapsed), logPath, compareDir, threshold)
+	if err != nil {
+		return nil, err
+	}
+	result.Regressions = regressions
+
+	return result, nil
+}
+
+// rate returns a share of successful answers, 1 if there are none.
+func rate(successful float64, failed float64) float64 {
+	if successful+failed == 0 {
+		return 1
+	}
+	return successful / (successful + failed)
+}
+
+type result struct {
+	ok     bool
+	report *answers.Report
+	err    error
+}
+
+// test tests answers concurrently and returns results in the answers order.
+func (t *Tester) test(aa []*answers.Answer) []*result {
+	results := make([]*result, len(aa))
+
+	indexes := make(chan int)
+	wg := &sync.WaitGroup{}
+	for i := 0; i < t.concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				ok, report, err := aa[i].Test(t.client)
+				if err != nil {
+					// a request failed to be sent is a failed answer.
+					report = &answers.Report{
+						URL:    aa[i].URL,
+						Method: aa[i].Method,
+						Body:   aa[i].Body,
+						Diff: &answers.Diff{
+							Error: fmt.Sprintf("can't send request: %s", err),
+						},
+					}
+				}
+				results[i] = &result{
+					ok:     ok,
+					report: report,
+					err:    err,
+				}
+			}
+		}()
+	}
+
+	for i := range aa {
+		indexes <- i
+	}
+	close(indexes)
+	wg.Wait()
+
+	return results
 }
 
 func writeToFile(filePath string, data interface{}) error {
@@ -120,19 +272,40 @@ func writeToFile(filePath string, data interface{}) error {
 	return err
 }
 
-func waitHealthcheck(url string) <-chan bool {
+// healthcheckRequestTimeout limits a single healthcheck request, so a hanging
+// application doesn't block the overall timeout.
+const healthcheckRequestTimeout = time.Second
+
+func waitHealthcheck(url string, timeout time.Duration) error {
 	log.Printf("waiting for status code 200 from: %s", url)
 
-	respChan := make(chan bool)
+	client := &http.Client{
+		Timeout: healthcheckRequestTimeout,
+	}
 
-	go func() {
-		for range time.Tick(time.Second) {
-			resp, _ := http.Get(url)
-			if resp != nil && resp.StatusCode == 200 {
-				respChan <- true
+	var deadline <-chan time.Time
+	if timeout > 0 {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		deadline = timer.C
+	}
+
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			resp, err := client.Get(url)
+			if err != nil {
+				continue
 			}
+			resp.Body.Close()
+			if resp.StatusCode == http.StatusOK {
+				return nil
+			}
+		case <-deadline:
+			return fmt.Errorf("no status code 200 from %s in %s", url, timeout)
 		}
-	}()
-
-	return respChan
+	}
 }
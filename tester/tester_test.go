@@ -0,0 +1,81 @@
+package tester
+
+import (
+	"io/ioutil"
+	"log"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+)
+
+func writeAnswers(t *testing.T, dir string, name string, data string) string {
+	path := filepath.Join(dir, name)
+	if err := ioutil.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestRunPhaseTransportError(t *testing.T) {
+	log.SetOutput(ioutil.Discard)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/accounts/1/" {
+			// the connection is closed without a response.
+			conn, _, err := w.(http.Hijacker).Hijack()
+			if err == nil {
+				conn.Close()
+			}
+			return
+		}
+		w.Write([]byte(`{"accounts":[]}`))
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	tt, err := New(server.URL, 2,
+		&PhaseConfig{
+			Name: "phase_1",
+			DataPath: writeAnswers(t, dir, "phase_1.answ", ""+
+				"GET	/accounts/filter/?query_id=1	200	{\"accounts\":[]}\n"+
+				"GET	/accounts/1/?query_id=2	200	{}\n"+
+				"GET	/accounts/filter/?query_id=3	200	{\"accounts\":[]}\n",
+			),
+		},
+		&PhaseConfig{
+			Name:     "phase_2",
+			DataPath: writeAnswers(t, dir, "phase_2.answ", ""),
+		},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i, c := range []struct {
+		successful int
+		failed     int
+		rate       float64
+	}{
+		{successful: 2, failed: 1, rate: 2.0 / 3},
+		// a phase without answers has no failures.
+		{successful: 0, failed: 0, rate: 1},
+	} {
+		phase := tt.phases[i]
+
+		result, err := tt.runPhase(phase, filepath.Join(dir, "logs", phase.Name), "", 0)
+		if err != nil {
+			t.Fatalf("%s: %s", phase.Name, err)
+		}
+		if result.Successful != c.successful || result.Failed != c.failed || result.SuccessRate() != c.rate {
+			t.Fatalf("%s: expected %d successful and %d failed answers with rate %.2f, got %+v with rate %.2f",
+				phase.Name, c.successful, c.failed, c.rate, result, result.SuccessRate())
+		}
+		for _, summary := range result.Summaries {
+			if math.IsNaN(summary.SuccessRate) {
+				t.Fatalf("%s: success rate is NaN", phase.Name)
+			}
+		}
+	}
+}